@@ -0,0 +1,340 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap/zapcore"
+)
+
+// AsyncOverflowPolicy decides what happens to an entry written while the queue of the
+// asynchronous writer is full, see `WithAsyncWrites`.
+type AsyncOverflowPolicy uint8
+
+const (
+	// AsyncBlock blocks the logging call until the queue has room for the entry, no entry is
+	// ever lost.
+	AsyncBlock AsyncOverflowPolicy = iota
+
+	// AsyncDropOldest drops the oldest queued entry to make room for the new one.
+	AsyncDropOldest
+
+	// AsyncDropNewest drops the new entry, keeping the queued ones.
+	AsyncDropNewest
+)
+
+func (p AsyncOverflowPolicy) String() string {
+	switch p {
+	case AsyncBlock:
+		return "block"
+	case AsyncDropOldest:
+		return "drop_oldest"
+	case AsyncDropNewest:
+		return "drop_newest"
+	}
+
+	return "unknown"
+}
+
+type asyncOptions struct {
+	queueSize     int
+	flushInterval time.Duration
+	policy        AsyncOverflowPolicy
+}
+
+// AsyncOption configures the asynchronous writer, see `WithAsyncWrites`.
+type AsyncOption interface {
+	apply(o *asyncOptions)
+}
+
+type asyncOptionFunc func(o *asyncOptions)
+
+func (f asyncOptionFunc) apply(o *asyncOptions) {
+	f(o)
+}
+
+// AsyncQueueSize is the maximum number of entries waiting to be written, 1024 by default. The
+// function panics if the size is not positive.
+func AsyncQueueSize(entries int) AsyncOption {
+	if entries <= 0 {
+		panic(fmt.Errorf("invalid async queue size %d, it must be greater than 0", entries))
+	}
+
+	return asyncOptionFunc(func(o *asyncOptions) {
+		o.queueSize = entries
+	})
+}
+
+// AsyncFlushInterval is the maximum time an entry waits in the queue before being written, one
+// second by default. The queue is also written as soon as it's full. The function panics if the
+// interval is not positive.
+func AsyncFlushInterval(interval time.Duration) AsyncOption {
+	if interval <= 0 {
+		panic(fmt.Errorf("invalid async flush interval %s, it must be greater than 0", interval))
+	}
+
+	return asyncOptionFunc(func(o *asyncOptions) {
+		o.flushInterval = interval
+	})
+}
+
+// AsyncDropPolicy decides what happens to an entry written while the queue is full,
+// `AsyncBlock` by default.
+func AsyncDropPolicy(policy AsyncOverflowPolicy) AsyncOption {
+	return asyncOptionFunc(func(o *asyncOptions) {
+		o.policy = policy
+	})
+}
+
+// messageWriter is implemented by the outputs for which each write is a distinct message, like
+// the syslog ones, the queued entries are then written one by one instead of in a single batch.
+type messageWriter interface {
+	writesMessages()
+}
+
+// asyncWriters holds the asynchronous writers shared by all the loggers, one per output.
+type asyncWriters struct {
+	options asyncOptions
+
+	lock    sync.Mutex
+	writers map[string]*asyncWriter
+	stopped bool
+}
+
+func newAsyncWriters(options ...AsyncOption) *asyncWriters {
+	writers := &asyncWriters{
+		options: asyncOptions{queueSize: 1024, flushInterval: time.Second, policy: AsyncBlock},
+		writers: map[string]*asyncWriter{},
+	}
+
+	for _, opt := range options {
+		opt.apply(&writers.options)
+	}
+
+	return writers
+}
+
+// wrap returns the asynchronous writer of the output named `name`, writing to `out` when first
+// created. Once stopped, `out` is returned as is.
+func (w *asyncWriters) wrap(name string, out zapcore.WriteSyncer) zapcore.WriteSyncer {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.stopped {
+		if writer, found := w.writers[name]; found {
+			return writer
+		}
+
+		return out
+	}
+
+	writer, found := w.writers[name]
+	if !found {
+		writer = newAsyncWriter(out, w.options)
+		w.writers[name] = writer
+	}
+
+	return writer
+}
+
+func (w *asyncWriters) dropped() (out uint64) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, writer := range w.writers {
+		out += writer.dropped.Load()
+	}
+
+	return
+}
+
+func (w *asyncWriters) stop() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.stopped = true
+	for _, writer := range w.writers {
+		writer.stop()
+	}
+}
+
+// asyncWriter is a `zapcore.WriteSyncer` queuing the entries and writing them in batches from a
+// background goroutine, `Sync` writes the queued entries before syncing the output. Since zap
+// syncs the core after writing an entry above the error level, DPanic, Panic and Fatal entries
+// are written before the process possibly exits.
+type asyncWriter struct {
+	out     zapcore.WriteSyncer
+	options asyncOptions
+
+	lock    sync.Mutex
+	notFull *sync.Cond
+	queue   [][]byte
+	stopped bool
+
+	// writeLock orders the batches written by the background goroutine and `Sync`
+	writeLock sync.Mutex
+
+	dropped *atomic.Uint64
+	wakeup  chan struct{}
+	done    chan struct{}
+}
+
+func newAsyncWriter(out zapcore.WriteSyncer, options asyncOptions) *asyncWriter {
+	w := &asyncWriter{
+		out:     out,
+		options: options,
+		dropped: atomic.NewUint64(0),
+		wakeup:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	w.notFull = sync.NewCond(&w.lock)
+
+	go w.run()
+
+	return w
+}
+
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+
+	if w.stopped {
+		w.lock.Unlock()
+
+		w.writeLock.Lock()
+		defer w.writeLock.Unlock()
+
+		return w.out.Write(p)
+	}
+
+	for len(w.queue) >= w.options.queueSize {
+		switch w.options.policy {
+		case AsyncDropNewest:
+			w.dropped.Inc()
+			w.lock.Unlock()
+			return len(p), nil
+
+		case AsyncDropOldest:
+			w.queue[0] = nil
+			w.queue = w.queue[1:]
+			w.dropped.Inc()
+
+		default:
+			w.wake()
+			w.notFull.Wait()
+
+			if w.stopped {
+				w.lock.Unlock()
+				return w.Write(p)
+			}
+		}
+	}
+
+	// The buffer is reused by zap once written, it must be copied
+	w.queue = append(w.queue, append([]byte(nil), p...))
+	if len(w.queue) >= w.options.queueSize {
+		w.wake()
+	}
+
+	w.lock.Unlock()
+	return len(p), nil
+}
+
+// Sync writes the queued entries then syncs the output.
+func (w *asyncWriter) Sync() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	return w.out.Sync()
+}
+
+func (w *asyncWriter) wake() {
+	select {
+	case w.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (w *asyncWriter) run() {
+	ticker := time.NewTicker(w.options.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.wakeup:
+		case <-w.done:
+			return
+		}
+
+		// There is nobody to report the error to, the lost entries are counted as dropped
+		w.flush()
+	}
+}
+
+// flush writes the queued entries as a single write, or one write per entry for a `messageWriter`.
+// The entries that can't be written are lost and counted as dropped, the first error is returned.
+func (w *asyncWriter) flush() error {
+	w.writeLock.Lock()
+	defer w.writeLock.Unlock()
+
+	w.lock.Lock()
+	queue := w.queue
+	w.queue = nil
+	w.notFull.Broadcast()
+	w.lock.Unlock()
+
+	if len(queue) == 0 {
+		return nil
+	}
+
+	if _, ok := w.out.(messageWriter); ok {
+		var firstErr error
+		for _, entry := range queue {
+			if _, err := w.out.Write(entry); err != nil {
+				w.dropped.Inc()
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+
+		return firstErr
+	}
+
+	size := 0
+	for _, entry := range queue {
+		size += len(entry)
+	}
+
+	batch := make([]byte, 0, size)
+	for _, entry := range queue {
+		batch = append(batch, entry...)
+	}
+
+	if _, err := w.out.Write(batch); err != nil {
+		w.dropped.Add(uint64(len(queue)))
+		return err
+	}
+
+	return nil
+}
+
+// stop writes the queued entries and stops the background goroutine, following writes are
+// performed synchronously.
+func (w *asyncWriter) stop() {
+	w.lock.Lock()
+	if w.stopped {
+		w.lock.Unlock()
+		return
+	}
+
+	w.stopped = true
+	w.notFull.Broadcast()
+	w.lock.Unlock()
+
+	close(w.done)
+	w.flush()
+}
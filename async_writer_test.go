@@ -0,0 +1,258 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAsyncWriter_DropPolicies(t *testing.T) {
+	tests := []struct {
+		policy       AsyncOverflowPolicy
+		expected     string
+		expectedDrop uint64
+	}{
+		{AsyncDropOldest, "c\nd\n", 2},
+		{AsyncDropNewest, "a\nb\n", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			out := &memorySyncer{}
+			writer := newAsyncWriter(out, asyncOptions{queueSize: 2, flushInterval: time.Hour, policy: test.policy})
+			defer writer.stop()
+
+			// Holding the write lock prevents the background goroutine from emptying the full queue
+			writer.writeLock.Lock()
+			for _, line := range []string{"a\n", "b\n", "c\n", "d\n"} {
+				writeAsync(t, writer, line)
+			}
+			writer.writeLock.Unlock()
+
+			require.NoError(t, writer.Sync())
+			assert.Equal(t, test.expected, out.String())
+			assert.Equal(t, test.expectedDrop, writer.dropped.Load())
+		})
+	}
+}
+
+func TestAsyncWriter_Block(t *testing.T) {
+	out := &memorySyncer{}
+	writer := newAsyncWriter(out, asyncOptions{queueSize: 2, flushInterval: time.Hour, policy: AsyncBlock})
+	defer writer.stop()
+
+	for i := 0; i < 100; i++ {
+		writeAsync(t, writer, "line\n")
+	}
+
+	require.NoError(t, writer.Sync())
+	assert.Equal(t, strings.Repeat("line\n", 100), out.String())
+	assert.Equal(t, uint64(0), writer.dropped.Load())
+	assert.Equal(t, 1, out.syncs)
+}
+
+func TestAsyncWriter_MessageWriter(t *testing.T) {
+	out := &messageSyncer{}
+	writer := newAsyncWriter(out, asyncOptions{queueSize: 10, flushInterval: time.Hour})
+	defer writer.stop()
+
+	writeAsync(t, writer, "first")
+	writeAsync(t, writer, "second")
+	require.NoError(t, writer.Sync())
+
+	assert.Equal(t, []string{"first", "second"}, out.messages)
+}
+
+func TestAsyncWriter_WriteErrorsCountedAsDropped(t *testing.T) {
+	out := &messageSyncer{fail: map[string]bool{"second": true}}
+	writer := newAsyncWriter(out, asyncOptions{queueSize: 10, flushInterval: time.Hour})
+	defer writer.stop()
+
+	writeAsync(t, writer, "first")
+	writeAsync(t, writer, "second")
+	writeAsync(t, writer, "third")
+	require.Error(t, writer.Sync())
+
+	// The messages following the failed one are still written
+	assert.Equal(t, []string{"first", "third"}, out.messages)
+	assert.Equal(t, uint64(1), writer.dropped.Load())
+
+	batchOut := &memorySyncer{err: errors.New("disk full")}
+	batchWriter := newAsyncWriter(batchOut, asyncOptions{queueSize: 10, flushInterval: time.Hour})
+	defer batchWriter.stop()
+
+	writeAsync(t, batchWriter, "a\n")
+	writeAsync(t, batchWriter, "b\n")
+	require.EqualError(t, batchWriter.Sync(), "disk full")
+	assert.Equal(t, uint64(2), batchWriter.dropped.Load())
+}
+
+func TestAsyncWriter_FlushInterval(t *testing.T) {
+	out := &memorySyncer{}
+	writer := newAsyncWriter(out, asyncOptions{queueSize: 10, flushInterval: 5 * time.Millisecond})
+	defer writer.stop()
+
+	writeAsync(t, writer, "line\n")
+	require.Eventually(t, func() bool { return out.String() == "line\n" }, time.Second, time.Millisecond)
+}
+
+func TestAsyncWriter_Stop(t *testing.T) {
+	out := &memorySyncer{}
+	writer := newAsyncWriter(out, asyncOptions{queueSize: 10, flushInterval: time.Hour})
+
+	writeAsync(t, writer, "queued\n")
+	writer.stop()
+	assert.Equal(t, "queued\n", out.String())
+
+	writeAsync(t, writer, "direct\n")
+	assert.Equal(t, "queued\ndirect\n", out.String())
+}
+
+func TestAsyncWriter_FlushOnPanic(t *testing.T) {
+	out := &memorySyncer{}
+	writer := newAsyncWriter(out, asyncOptions{queueSize: 10, flushInterval: time.Hour})
+	defer writer.stop()
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = ""
+	logger := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), writer, zapcore.DebugLevel))
+
+	logger.Info("queued")
+	assert.Equal(t, "", out.String())
+
+	assert.Panics(t, func() { logger.Panic("boom") })
+	assert.Equal(t, `{"level":"info","msg":"queued"}`+"\n"+`{"level":"panic","msg":"boom"}`+"\n", out.String())
+}
+
+func TestAsyncOptions_Invalid(t *testing.T) {
+	assert.PanicsWithError(t, "invalid async queue size 0, it must be greater than 0", func() { AsyncQueueSize(0) })
+	assert.PanicsWithError(t, "invalid async queue size -1, it must be greater than 0", func() { AsyncQueueSize(-1) })
+	assert.PanicsWithError(t, "invalid async flush interval 0s, it must be greater than 0", func() { AsyncFlushInterval(0) })
+	assert.PanicsWithError(t, "invalid async flush interval -1s, it must be greater than 0", func() { AsyncFlushInterval(-time.Second) })
+}
+
+func TestInstantiate_AsyncWrites(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+
+	registry := newRegistry("test", dbgZlog)
+	libLogger, _ := packageLogger(registry, "lib", "com/lib")
+	logger, _ := applicationLogger(registry, fakeEnv(map[string]string{"DLOG": "com/lib=info"}), "test", "com/test",
+		WithOutputToFile(logFile),
+		WithFormat(FormatText, FormatLogfmt),
+		WithAsyncWrites(AsyncFlushInterval(time.Hour)),
+		WithProductionDetector(func() bool { return false }),
+	)
+
+	logger.Info("application line")
+	libLogger.Info("library line")
+	assert.Empty(t, readLogLines(t, logFile))
+
+	// Stderr cannot be synced, the error is expected
+	_ = logger.Sync()
+	lines := readLogLines(t, logFile)
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `level=info logger=test msg="application line"`)
+	assert.Contains(t, lines[1], `level=info logger=lib msg="library line"`)
+}
+
+func TestInstance_CloseFlushesAsyncWrites(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "app.log")
+
+	registry := newRegistry("test", dbgZlog)
+	logger, _ := rootLogger(registry, "test", "com/test")
+
+	instance, err := instantiate(registry, noEnv, newInstantiateOptions(WithOutputToFile(logFile), WithFormat(FormatText, FormatLogfmt), WithAsyncWrites(AsyncFlushInterval(time.Hour)), WithProductionDetector(func() bool { return false })))
+	require.NoError(t, err)
+
+	logger.Info("before close")
+	require.NoError(t, instance.Close(context.Background()))
+
+	lines := readLogLines(t, logFile)
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `msg="before close"`)
+	assert.Equal(t, uint64(0), instance.DroppedLogEntries())
+}
+
+// memorySyncer is an in-memory `zapcore.WriteSyncer` counting the syncs.
+type memorySyncer struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+	syncs  int
+	err    error
+}
+
+func (s *memorySyncer) Write(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	return s.buffer.Write(p)
+}
+
+func (s *memorySyncer) Sync() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.syncs++
+	return nil
+}
+
+func (s *memorySyncer) String() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.buffer.String()
+}
+
+// messageSyncer records each write as a distinct message, like the syslog writer expects.
+type messageSyncer struct {
+	messages []string
+	fail     map[string]bool
+}
+
+func (s *messageSyncer) Write(p []byte) (int, error) {
+	if s.fail[string(p)] {
+		return 0, fmt.Errorf("unable to write %q", p)
+	}
+
+	s.messages = append(s.messages, string(p))
+	return len(p), nil
+}
+
+func (s *messageSyncer) Sync() error     { return nil }
+func (s *messageSyncer) writesMessages() {}
+
+func readLogLines(t *testing.T, path string) []string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	if len(content) == 0 {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+func writeAsync(t *testing.T, writer *asyncWriter, content string) {
+	t.Helper()
+
+	_, err := writer.Write([]byte(content))
+	require.NoError(t, err)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// loggerStatus mirrors the JSON representation of a logger returned by the level switcher server.
+type loggerStatus struct {
+	ShortName     string               `json:"short_name"`
+	PackageID     string               `json:"package_id"`
+	Level         string               `json:"level"`
+	Trace         bool                 `json:"trace"`
+	Root          bool                 `json:"root"`
+	Origin        originStatus         `json:"origin"`
+	PendingRevert *pendingRevertStatus `json:"pending_revert,omitempty"`
+}
+
+type pendingRevertStatus struct {
+	Level    string       `json:"level"`
+	Trace    bool         `json:"trace"`
+	Origin   originStatus `json:"origin"`
+	RevertAt time.Time    `json:"revert_at"`
+}
+
+type originStatus struct {
+	Source   string `json:"source"`
+	Key      string `json:"key,omitempty"`
+	Ordering int    `json:"ordering,omitempty"`
+	TTL      string `json:"ttl,omitempty"`
+}
+
+type loggersResp struct {
+	Loggers []loggerStatus `json:"loggers"`
+}
+
+type logChangeReq struct {
+	Inputs string `json:"inputs"`
+	Level  string `json:"level,omitempty"`
+	TTL    string `json:"ttl,omitempty"`
+}
+
+// client talks to a level switcher server listening either on a TCP address or on a unix socket.
+type client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// unixSocketAddrPrefix is the prefix of the `-addr` values designating a unix socket, like the
+// listening addresses of the level switcher server.
+const unixSocketAddrPrefix = "unix://"
+
+func newClient(addr string, socket string, token string) *client {
+	if socket == "" && strings.HasPrefix(addr, unixSocketAddrPrefix) {
+		socket = strings.TrimPrefix(addr, unixSocketAddrPrefix)
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	baseURL := addr
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+
+	if socket != "" {
+		baseURL = "http://localhost"
+		httpClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}
+	}
+
+	return &client{baseURL: strings.TrimSuffix(baseURL, "/"), token: token, httpClient: httpClient}
+}
+
+// loggers returns the raw JSON response listing the loggers registered under `key`, or all of
+// them if `key` is empty, along with its decoded form.
+func (c *client) loggers(key string) (raw []byte, out loggersResp, err error) {
+	path := "/"
+	if key != "" {
+		path = (&url.URL{Path: "/loggers/" + key}).EscapedPath()
+	}
+
+	raw, err = c.do(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, out, err
+	}
+
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, out, fmt.Errorf("decode response: %w", err)
+	}
+
+	return raw, out, nil
+}
+
+func (c *client) setLevel(inputs string, level string, ttl string) error {
+	_, err := c.do(http.MethodPut, "/", &logChangeReq{Inputs: inputs, Level: level, TTL: ttl})
+	return err
+}
+
+func (c *client) revert(inputs string) error {
+	_, err := c.do(http.MethodDelete, "/", &logChangeReq{Inputs: inputs})
+	return err
+}
+
+func (c *client) reset(inputs string) error {
+	_, err := c.do(http.MethodPost, "/reset", &logChangeReq{Inputs: inputs})
+	return err
+}
+
+func (c *client) do(method string, path string, in interface{}) ([]byte, error) {
+	var body io.Reader
+	if in != nil {
+		content, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+
+		body = bytes.NewReader(content)
+	}
+
+	request, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("level switcher server answered %s: %s", response.Status, strings.TrimSpace(string(content)))
+	}
+
+	return content, nil
+}
@@ -0,0 +1,311 @@
+// Command logctl controls the log levels of a process through its level switcher server, see
+// `logging.WithLogLevelSwitcherServerAutoStart`.
+//
+// Usage:
+//
+//	logctl [flags] list [<shortName or packageID>]
+//	logctl [flags] set [-ttl <duration>] <inputs> <level>
+//	logctl [flags] revert <inputs>
+//	logctl [flags] reset [<inputs>]
+//	logctl [flags] explain <shortName or packageID>
+//
+// The `<inputs>` are the same as the `DEBUG` environment variable, a comma-separated list of
+// short names, package IDs or regexes, where `true` matches all loggers.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: logctl [flags] <command> [arguments]
+
+Commands:
+  list [<key>]                   List the registered loggers, all of them or those registered under <key>
+  set [-ttl <duration>] <inputs> <level>
+                                 Set the level of the loggers matching <inputs>, temporarily if -ttl is set
+  revert <inputs>                Revert the loggers matching <inputs> that have a pending time-limited change
+  reset [<inputs>]               Reset the loggers matching <inputs> (all if unset) to their instantiated level
+  explain <key>                  Explain why the loggers registered under <key> are at their level
+
+Flags:
+`
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr, os.Getenv); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		}
+
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer, getenv func(string) string) error {
+	flags := flag.NewFlagSet("logctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	addr := flags.String("addr", envOr(getenv, "LOGCTL_ADDR", "localhost:1065"), "TCP address of the level switcher server, or unix://<path> for a unix socket (env LOGCTL_ADDR)")
+	socket := flags.String("socket", getenv("LOGCTL_SOCKET"), "Unix socket path of the level switcher server, takes precedence over -addr (env LOGCTL_SOCKET)")
+	token := flags.String("token", getenv("LOGGING_SWITCHER_SERVER_TOKEN"), "Bearer token of the level switcher server (env LOGGING_SWITCHER_SERVER_TOKEN)")
+	asJSON := flags.Bool("json", false, "Output the level switcher server JSON responses instead of tables")
+
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	client := newClient(*addr, *socket, *token)
+	out := &output{writer: stdout, json: *asJSON}
+
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "list":
+		return runList(client, out, commandArgs)
+	case "set":
+		return runSet(client, out, commandArgs, stderr)
+	case "revert":
+		return runRevert(client, out, commandArgs)
+	case "reset":
+		return runReset(client, out, commandArgs)
+	case "explain":
+		return runExplain(client, out, commandArgs)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n", command)
+		flags.Usage()
+		return errUsage
+	}
+}
+
+func runList(client *client, out *output, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("list accepts at most one argument, got %d", len(args))
+	}
+
+	key := ""
+	if len(args) == 1 {
+		key = args[0]
+	}
+
+	raw, resp, err := client.loggers(key)
+	if err != nil {
+		return err
+	}
+
+	return out.loggers(raw, resp.Loggers)
+}
+
+func runSet(client *client, out *output, args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("set", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	ttl := flags.Duration("ttl", 0, "Revert the change once the duration elapsed, like 10m")
+
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("set expects <inputs> and <level> arguments, got %d argument(s)", flags.NArg())
+	}
+
+	ttlValue := ""
+	if *ttl != 0 {
+		ttlValue = ttl.String()
+	}
+
+	inputs := flags.Arg(0)
+	if err := client.setLevel(inputs, flags.Arg(1), ttlValue); err != nil {
+		return err
+	}
+
+	return listMatching(client, out, inputs)
+}
+
+func runRevert(client *client, out *output, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("revert expects an <inputs> argument, got %d argument(s)", len(args))
+	}
+
+	if err := client.revert(args[0]); err != nil {
+		return err
+	}
+
+	return listMatching(client, out, args[0])
+}
+
+func runReset(client *client, out *output, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("reset accepts at most one argument, got %d", len(args))
+	}
+
+	inputs := "true"
+	if len(args) == 1 {
+		inputs = args[0]
+	}
+
+	if err := client.reset(inputs); err != nil {
+		return err
+	}
+
+	return listMatching(client, out, inputs)
+}
+
+func runExplain(client *client, out *output, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("explain expects a <key> argument, got %d argument(s)", len(args))
+	}
+
+	raw, resp, err := client.loggers(args[0])
+	if err != nil {
+		return err
+	}
+
+	return out.explanations(raw, resp.Loggers)
+}
+
+// listMatching lists the loggers once a change has been made, when `inputs` is a single short
+// name or package ID only the loggers registered under it are listed, all of them otherwise.
+func listMatching(client *client, out *output, inputs string) error {
+	key := inputs
+	if strings.Contains(inputs, ",") || inputs == "true" || inputs == "*" {
+		key = ""
+	}
+
+	raw, resp, err := client.loggers(key)
+	if err != nil {
+		// The key might be a regex which the listing endpoint does not support
+		raw, resp, err = client.loggers("")
+		if err != nil {
+			return err
+		}
+	}
+
+	return out.loggers(raw, resp.Loggers)
+}
+
+type output struct {
+	writer io.Writer
+	json   bool
+}
+
+func (o *output) loggers(raw []byte, loggers []loggerStatus) error {
+	if o.json {
+		return o.rawJSON(raw)
+	}
+
+	sortLoggers(loggers)
+
+	table := tabwriter.NewWriter(o.writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "SHORT NAME\tPACKAGE ID\tLEVEL\tORIGIN\tPENDING REVERT")
+	for _, logger := range loggers {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", logger.ShortName, logger.PackageID, levelString(logger.Level, logger.Trace), logger.Origin.Source, pendingRevertString(logger.PendingRevert))
+	}
+
+	return table.Flush()
+}
+
+func (o *output) explanations(raw []byte, loggers []loggerStatus) error {
+	if o.json {
+		return o.rawJSON(raw)
+	}
+
+	sortLoggers(loggers)
+
+	for i, logger := range loggers {
+		if i > 0 {
+			fmt.Fprintln(o.writer)
+		}
+
+		table := tabwriter.NewWriter(o.writer, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(table, "Logger:\t%s (%s)\n", logger.PackageID, logger.ShortName)
+		fmt.Fprintf(table, "Level:\t%s\n", levelString(logger.Level, logger.Trace))
+		fmt.Fprintf(table, "Set by:\t%s\n", originString(logger.Origin))
+
+		if revert := logger.PendingRevert; revert != nil {
+			fmt.Fprintf(table, "Reverts to:\t%s at %s\n", levelString(revert.Level, revert.Trace), revert.RevertAt.Local().Format(time.RFC3339))
+			fmt.Fprintf(table, "Reverted level set by:\t%s\n", originString(revert.Origin))
+		}
+
+		if err := table.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *output) rawJSON(raw []byte) error {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, raw, "", "  "); err != nil {
+		return fmt.Errorf("indent response: %w", err)
+	}
+
+	_, err := fmt.Fprintln(o.writer, strings.TrimSpace(indented.String()))
+	return err
+}
+
+func sortLoggers(loggers []loggerStatus) {
+	sort.Slice(loggers, func(i, j int) bool {
+		return loggers[i].PackageID < loggers[j].PackageID
+	})
+}
+
+func levelString(level string, trace bool) string {
+	if trace {
+		return "trace"
+	}
+
+	return level
+}
+
+func pendingRevertString(revert *pendingRevertStatus) string {
+	if revert == nil {
+		return "-"
+	}
+
+	return fmt.Sprintf("%s in %s", levelString(revert.Level, revert.Trace), time.Until(revert.RevertAt).Round(time.Second))
+}
+
+func originString(origin originStatus) string {
+	out := origin.Source
+	if origin.Key != "" {
+		out += fmt.Sprintf(", matched by key %q", origin.Key)
+	}
+
+	if origin.Ordering != 0 {
+		out += fmt.Sprintf(" (element #%d)", origin.Ordering)
+	}
+
+	if origin.TTL != "" {
+		out += fmt.Sprintf(", for %s", origin.TTL)
+	}
+
+	return out
+}
+
+func envOr(getenv func(string) string, key string, defaultValue string) string {
+	if value := getenv(key); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/streamingfast/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var _, _ = logging.PackageLogger("lib", "github.com/acme/lib")
+var _, _ = logging.PackageLogger("lib", "github.com/acme/lib/sub")
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(logging.LevelSwitcherHandler(logging.SwitcherServerToken("secret")))
+	defer server.Close()
+
+	env := map[string]string{"LOGCTL_ADDR": server.URL, "LOGGING_SWITCHER_SERVER_TOKEN": "secret"}
+	logctl := func(args ...string) (string, error) {
+		var stdout, stderr bytes.Buffer
+		err := run(args, &stdout, &stderr, func(key string) string { return env[key] })
+
+		return stdout.String(), err
+	}
+
+	out, err := logctl("list", "lib")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"SHORT NAME  PACKAGE ID               LEVEL  ORIGIN   PENDING REVERT",
+		"lib         github.com/acme/lib      error  default  -",
+		"lib         github.com/acme/lib/sub  error  default  -",
+		"",
+	}, "\n"), out)
+
+	out, err = logctl("set", "github.com/acme/lib/sub", "trace")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"SHORT NAME  PACKAGE ID               LEVEL  ORIGIN           PENDING REVERT",
+		"lib         github.com/acme/lib/sub  trace  switcher_server  -",
+		"",
+	}, "\n"), out)
+
+	out, err = logctl("explain", "github.com/acme/lib/sub")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"Logger:  github.com/acme/lib/sub (lib)",
+		"Level:   trace",
+		`Set by:  switcher_server, matched by key "github.com/acme/lib/sub" (element #1)`,
+		"",
+	}, "\n"), out)
+
+	_, err = logctl("set", "-ttl", "1h", "lib", "info")
+	require.NoError(t, err)
+
+	out, err = logctl("-json", "list", "github.com/acme/lib")
+	require.NoError(t, err)
+
+	var resp loggersResp
+	require.NoError(t, json.Unmarshal([]byte(out), &resp))
+	require.Len(t, resp.Loggers, 1)
+	assert.Equal(t, "info", resp.Loggers[0].Level)
+	assert.Equal(t, originStatus{Source: "switcher_server", Key: "lib", Ordering: 1, TTL: "1h0m0s"}, resp.Loggers[0].Origin)
+	require.NotNil(t, resp.Loggers[0].PendingRevert)
+	assert.Equal(t, "error", resp.Loggers[0].PendingRevert.Level)
+
+	_, err = logctl("reset")
+	require.NoError(t, err)
+
+	explanation, _ := logging.Explain("github.com/acme/lib/sub")
+	assert.Equal(t, zap.ErrorLevel, explanation.Level)
+	assert.False(t, explanation.Trace)
+	assert.Nil(t, explanation.PendingRevert)
+}
+
+func TestRun_UnixSocketAddr(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "switcher.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	server := &http.Server{Handler: logging.LevelSwitcherHandler()}
+	go server.Serve(listener)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"-addr", "unix://" + socket, "list", "github.com/acme/lib"}, &stdout, &stderr, func(string) string { return "" }))
+	assert.Contains(t, stdout.String(), "github.com/acme/lib ")
+}
+
+func TestRun_Errors(t *testing.T) {
+	server := httptest.NewServer(logging.LevelSwitcherHandler(logging.SwitcherServerToken("secret")))
+	defer server.Close()
+
+	logctl := func(args ...string) error {
+		var stdout, stderr bytes.Buffer
+		return run(append([]string{"-addr", server.URL}, args...), &stdout, &stderr, func(string) string { return "" })
+	}
+
+	assert.EqualError(t, logctl("list"), "level switcher server answered 401 Unauthorized: unauthorized")
+	assert.ErrorIs(t, logctl("unknown"), errUsage)
+	assert.ErrorIs(t, logctl(), errUsage)
+	assert.EqualError(t, logctl("-token", "secret", "set", "lib"), "set expects <inputs> and <level> arguments, got 1 argument(s)")
+	assert.Contains(t, logctl("-token", "secret", "set", "lib", "debgu").Error(), "400 Bad Request: invalid level value")
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// The keys holding the `zapcore.Entry` properties, the Stackdriver key
+// (`zapdriver.NewProductionEncoderConfig`) first then the plain zap key
+// (`zap.NewProductionEncoderConfig`) when they differ.
+var (
+	levelKeys      = []string{"severity", "level"}
+	timeKeys       = []string{"timestamp", "ts"}
+	loggerKeys     = []string{"logger"}
+	callerKeys     = []string{"caller"}
+	messageKeys    = []string{"message", "msg"}
+	stacktraceKeys = []string{"stacktrace"}
+)
+
+// stackdriverLevels maps the Stackdriver severities to zap levels, see `zapdriver.EncodeLevel`.
+var stackdriverLevels = map[string]zapcore.Level{
+	"DEFAULT":   zapcore.InfoLevel,
+	"DEBUG":     zapcore.DebugLevel,
+	"INFO":      zapcore.InfoLevel,
+	"NOTICE":    zapcore.InfoLevel,
+	"WARNING":   zapcore.WarnLevel,
+	"ERROR":     zapcore.ErrorLevel,
+	"CRITICAL":  zapcore.DPanicLevel,
+	"ALERT":     zapcore.PanicLevel,
+	"EMERGENCY": zapcore.FatalLevel,
+}
+
+// stackdriverKeys are the fields added by `zapdriver.WrapCore` that only matter to Stackdriver
+// and are dropped unless all fields are requested.
+var stackdriverKeys = map[string]bool{
+	"serviceContext":                        true,
+	"logging.googleapis.com/sourceLocation": true,
+}
+
+type jsonField struct {
+	key   string
+	value json.RawMessage
+}
+
+// decodeLine reconstructs the entry and its fields out of a JSON log line, the returned bool is
+// false when the line is not a JSON log line, i.e. not a JSON object or without a message nor a
+// level.
+func decodeLine(line []byte, allFields bool) (entry zapcore.Entry, fields []zapcore.Field, ok bool) {
+	jsonFields, err := decodeObject(line)
+	if err != nil {
+		return entry, nil, false
+	}
+
+	var hasLevel, hasMessage bool
+	entry.Level = zapcore.InfoLevel
+
+	for _, field := range jsonFields {
+		switch {
+		case isOneOf(field.key, levelKeys):
+			if entry.Level, ok = decodeLevel(field.value); !ok {
+				return entry, nil, false
+			}
+			hasLevel = true
+
+		case isOneOf(field.key, timeKeys):
+			if entry.Time, ok = decodeTime(field.value); !ok {
+				fields = append(fields, decodeField(field))
+			}
+
+		case isOneOf(field.key, loggerKeys):
+			if entry.LoggerName, ok = decodeString(field.value); !ok {
+				fields = append(fields, decodeField(field))
+			}
+
+		case isOneOf(field.key, callerKeys):
+			if entry.Caller, ok = decodeCaller(field.value); !ok {
+				fields = append(fields, decodeField(field))
+			}
+
+		case isOneOf(field.key, messageKeys):
+			if entry.Message, ok = decodeString(field.value); !ok {
+				return entry, nil, false
+			}
+			hasMessage = true
+
+		case isOneOf(field.key, stacktraceKeys):
+			if entry.Stack, ok = decodeString(field.value); !ok {
+				fields = append(fields, decodeField(field))
+			}
+
+		default:
+			if !allFields && isStackdriverField(field) {
+				continue
+			}
+
+			fields = append(fields, decodeField(field))
+		}
+	}
+
+	if !hasLevel && !hasMessage {
+		return entry, nil, false
+	}
+
+	return entry, fields, true
+}
+
+// decodeObject decodes the top-level keys of a JSON object, preserving their order.
+func decodeObject(line []byte) ([]jsonField, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	var fields []jsonField
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, jsonField{key: token.(string), value: value})
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	// Anything but white spaces after the object means it was not a JSON log line
+	if len(bytes.TrimSpace(line[decoder.InputOffset():])) != 0 {
+		return nil, fmt.Errorf("unexpected content after JSON object")
+	}
+
+	return fields, nil
+}
+
+func decodeLevel(value json.RawMessage) (zapcore.Level, bool) {
+	in, ok := decodeString(value)
+	if !ok {
+		return zapcore.InfoLevel, false
+	}
+
+	if level, found := stackdriverLevels[strings.ToUpper(in)]; found {
+		return level, true
+	}
+
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(in)); err != nil {
+		return zapcore.InfoLevel, false
+	}
+
+	return level, true
+}
+
+// decodeTime accepts RFC3339 strings as written by `zapdriver.RFC3339NanoTimeEncoder` and
+// `zapcore.ISO8601TimeEncoder` as well as the floating point seconds since epoch written by
+// `zapcore.EpochTimeEncoder`.
+func decodeTime(value json.RawMessage) (time.Time, bool) {
+	if in, ok := decodeString(value); ok {
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000Z0700"} {
+			if t, err := time.Parse(layout, in); err == nil {
+				return t, true
+			}
+		}
+
+		return time.Time{}, false
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(value, &number); err != nil {
+		return time.Time{}, false
+	}
+
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, int64(seconds*float64(time.Second))).UTC(), true
+}
+
+// decodeCaller decodes the `<path>:<line>` form written by `zapcore.ShortCallerEncoder` and
+// `zapcore.FullCallerEncoder`.
+func decodeCaller(value json.RawMessage) (zapcore.EntryCaller, bool) {
+	in, ok := decodeString(value)
+	if !ok {
+		return zapcore.EntryCaller{}, false
+	}
+
+	separator := strings.LastIndex(in, ":")
+	if separator == -1 {
+		return zapcore.EntryCaller{}, false
+	}
+
+	line, err := strconv.Atoi(in[separator+1:])
+	if err != nil {
+		return zapcore.EntryCaller{}, false
+	}
+
+	return zapcore.EntryCaller{Defined: true, File: in[0:separator], Line: line}, true
+}
+
+func decodeString(value json.RawMessage) (string, bool) {
+	var out string
+	if err := json.Unmarshal(value, &out); err != nil {
+		return "", false
+	}
+
+	return out, true
+}
+
+// decodeField turns a JSON value back into a field, strings are re-encoded by the encoder while
+// other values are written as they were received.
+func decodeField(field jsonField) zapcore.Field {
+	if in, ok := decodeString(field.value); ok {
+		return zap.String(field.key, in)
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, field.value); err != nil {
+		return zap.Reflect(field.key, field.value)
+	}
+
+	return zap.Reflect(field.key, json.RawMessage(compacted.Bytes()))
+}
+
+// isStackdriverField returns true for the fields added by `zapdriver` for Stackdriver's own usage,
+// the `context` field being dropped only when it is the error reporting location added by
+// `zapdriver.ReportAllErrors`.
+func isStackdriverField(field jsonField) bool {
+	if stackdriverKeys[field.key] {
+		return true
+	}
+
+	if field.key != "context" {
+		return false
+	}
+
+	var context map[string]json.RawMessage
+	if err := json.Unmarshal(field.value, &context); err != nil {
+		return false
+	}
+
+	_, found := context["reportLocation"]
+	return found && len(context) == 1
+}
+
+func isOneOf(key string, candidates []string) bool {
+	for _, candidate := range candidates {
+		if key == candidate {
+			return true
+		}
+	}
+
+	return false
+}
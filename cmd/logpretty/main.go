@@ -0,0 +1,144 @@
+// Command logpretty renders JSON log lines, as written by the production console core
+// (`zapdriver.NewProductionEncoderConfig`) or by a plain zap JSON encoder, with the developer
+// friendly `logging.NewEncoder`, lines that are not JSON log lines are passed through untouched.
+//
+// Usage:
+//
+//	kubectl logs <pod> | logpretty [-v <verbosity>] [-color auto|always|never] [-all-fields]
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/streamingfast/logging"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const usage = `Usage: logpretty [flags] < <logs>
+
+Reads JSON log lines from standard input and renders them in the developer format, lines that
+are not JSON log lines are written as-is.
+
+Flags:
+`
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	isTTY := terminal.IsTerminal(int(os.Stdout.Fd()))
+
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, isTTY); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		}
+
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer, isTTY bool) error {
+	flags := flag.NewFlagSet("logpretty", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	verbosity := flags.Int("v", 1, "Verbosity of the rendering, 0 shows only the level and message, 1 adds time, logger and caller, 2 adds stack traces of all levels, 4 shows full caller paths")
+	color := flags.String("color", "auto", "Colorize the output, one of auto (only when writing to a terminal), always or never")
+	allFields := flags.Bool("all-fields", false, "Keep the fields only meaningful to Stackdriver (serviceContext, error reporting context, source location)")
+
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	enableColors, err := colorsEnabled(*color, isTTY)
+	if err != nil {
+		return err
+	}
+
+	printer := &printer{
+		encoder:   logging.NewEncoder(*verbosity, enableColors),
+		allFields: *allFields,
+	}
+
+	return printer.print(stdin, stdout)
+}
+
+func colorsEnabled(color string, isTTY bool) (bool, error) {
+	switch color {
+	case "auto":
+		return isTTY, nil
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid -color value %q, expected one of auto, always or never", color)
+	}
+}
+
+type printer struct {
+	encoder   zapcore.Encoder
+	allFields bool
+}
+
+func (p *printer) print(in io.Reader, out io.Writer) error {
+	reader := bufio.NewReader(in)
+	writer := bufio.NewWriter(out)
+	defer writer.Flush()
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if err := p.printLine(line, writer); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("read input: %w", err)
+		}
+
+		// Lines are flushed as they come so that `kubectl logs -f` is rendered live
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *printer) printLine(line []byte, out io.Writer) error {
+	entry, fields, ok := decodeLine(line, p.allFields)
+	if !ok {
+		_, err := out.Write(line)
+		return err
+	}
+
+	buffer, err := p.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		// Better to show the line as received than to lose it
+		_, err := out.Write(line)
+		return err
+	}
+	defer buffer.Free()
+
+	_, err = out.Write(buffer.Bytes())
+	return err
+}
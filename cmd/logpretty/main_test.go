@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blendle/zapdriver"
+	"github.com/streamingfast/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDecodeLine_RoundTrip(t *testing.T) {
+	entry := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2021, 3, 4, 5, 6, 7, 8000000, time.UTC),
+		LoggerName: "lib",
+		Message:    "block processed",
+		Caller:     zapcore.EntryCaller{Defined: true, File: "github.com/acme/lib/block.go", Line: 42},
+		Stack:      "main.main\n\t/app/main.go:12",
+	}
+	fields := []zapcore.Field{
+		zap.String("block", "00000001a"),
+		zap.Int("count", 3),
+		zap.Bool("final", true),
+		zap.Reflect("nested", map[string]interface{}{"id": 1}),
+	}
+
+	productionConfig := zap.NewProductionEncoderConfig()
+	productionConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	encoders := map[string]zapcore.Encoder{
+		"stackdriver": zapcore.NewJSONEncoder(zapdriver.NewProductionEncoderConfig()),
+		"zap":         zapcore.NewJSONEncoder(productionConfig),
+	}
+
+	expected := render(t, entry, fields)
+	for name, encoder := range encoders {
+		t.Run(name, func(t *testing.T) {
+			line, err := encoder.EncodeEntry(entry, append([]zapcore.Field{}, fields...))
+			require.NoError(t, err)
+
+			decodedEntry, decodedFields, ok := decodeLine(line.Bytes(), false)
+			require.True(t, ok)
+
+			assert.Equal(t, expected, render(t, decodedEntry, decodedFields))
+		})
+	}
+}
+
+func TestDecodeLine_StackdriverFields(t *testing.T) {
+	line := `{"severity":"ERROR","message":"failed","serviceContext":{"service":"app"},"context":{"reportLocation":{"filePath":"a.go","lineNumber":"1","functionName":"main"}},"error":"boom"}`
+
+	_, fields, ok := decodeLine([]byte(line), false)
+	require.True(t, ok)
+	assert.Equal(t, []string{"error"}, fieldKeys(fields))
+
+	_, fields, ok = decodeLine([]byte(line), true)
+	require.True(t, ok)
+	assert.Equal(t, []string{"serviceContext", "context", "error"}, fieldKeys(fields))
+
+	_, fields, ok = decodeLine([]byte(`{"msg":"user context","context":{"user":"a"}}`), false)
+	require.True(t, ok)
+	assert.Equal(t, []string{"context"}, fieldKeys(fields))
+}
+
+func TestDecodeLine_NotALogLine(t *testing.T) {
+	for _, line := range []string{
+		"panic: runtime error\n",
+		"",
+		`["severity","INFO"]`,
+		`{"severity":"INFO","message":"truncated"`,
+		`{"severity":"INFO","message":"trailing"} garbage`,
+		`{"key":"value"}`,
+		`{"severity":"LOUD","message":"unknown level"}`,
+	} {
+		_, _, ok := decodeLine([]byte(line), false)
+		assert.False(t, ok, line)
+	}
+}
+
+func TestRun(t *testing.T) {
+	input := strings.Join([]string{
+		`{"severity":"INFO","timestamp":"2021-03-04T05:06:07.008Z","logger":"app","caller":"app/main.go:10","message":"starting","port":8080}`,
+		`goroutine 1 [running]:`,
+		`{"level":"debug","ts":1614834367.008,"msg":"tick"}`,
+		``,
+	}, "\n")
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"-color", "never"}, strings.NewReader(input), &stdout, &stderr, true))
+
+	assert.Equal(t, strings.Join([]string{
+		`2021-03-04T05:06:07.008Z INFO (app) starting (app/main.go:10) {"port": 8080}`,
+		`goroutine 1 [running]:`,
+		`2021-03-04T05:06:07.008Z DEBG (<n/a>) tick`,
+		``,
+	}, "\n"), stdout.String())
+
+	assert.ErrorIs(t, run([]string{"extra"}, strings.NewReader(""), &stdout, &stderr, true), errUsage)
+	assert.EqualError(t, run([]string{"-color", "yes"}, strings.NewReader(""), &stdout, &stderr, true), `invalid -color value "yes", expected one of auto, always or never`)
+}
+
+func render(t *testing.T, entry zapcore.Entry, fields []zapcore.Field) string {
+	t.Helper()
+
+	buffer, err := logging.NewEncoder(4, false).EncodeEntry(entry, fields)
+	require.NoError(t, err)
+	defer buffer.Free()
+
+	return buffer.String()
+}
+
+func fieldKeys(fields []zapcore.Field) (out []string) {
+	for _, field := range fields {
+		out = append(out, field.Key)
+	}
+
+	return
+}
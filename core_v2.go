@@ -2,14 +2,15 @@ package logging
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/blendle/zapdriver"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"golang.org/x/crypto/ssh/terminal"
@@ -31,9 +32,21 @@ type instantiateOptions struct {
 	defaultLevel                     *zapcore.Level
 	logLevelSwitcherServerAutoStart  *bool
 	logLevelSwitcherServerListenAddr string
-	logToFile                        string
+	logLevelSwitcherServerToken      string
+	logFile                          *sharedLogFile
+	asyncWriters                     *asyncWriters
+	sinks                            []*sink
+	appName                          string
+	consoleJournal                   *journalWriter
+	consoleFormat                    Format
+	fileFormat                       Format
 	forceProductionLogger            bool
 	preSpec                          *logLevelSpec
+	preSpecErrors                    SpecErrors
+	specValidation                   specValidation
+	specFile                         string
+	specFilePollInterval             time.Duration
+	signalLevelToggle                bool
 	reportAllErrors                  *bool
 	productionLoggerDetector         func() bool
 
@@ -45,7 +58,7 @@ type instantiateOptions struct {
 }
 
 func newInstantiateOptions(opts ...InstantiateOption) instantiateOptions {
-	options := instantiateOptions{logLevelSwitcherServerListenAddr: "127.0.0.1:1065"}
+	options := instantiateOptions{logLevelSwitcherServerListenAddr: "127.0.0.1:1065", specFilePollInterval: 5 * time.Second}
 	for _, opt := range opts {
 		opt.apply(&options)
 	}
@@ -64,9 +77,19 @@ func newInstantiateOptions(opts ...InstantiateOption) instantiateOptions {
 func (o instantiateOptions) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 	encoder.AddString("default_level", ptrLevelToString(o.defaultLevel))
 	encoder.AddBool("force_production_logger", o.forceProductionLogger)
+	encoder.AddString("console_format", string(o.consoleFormat))
+	encoder.AddString("file_format", string(o.fileFormat))
+	encoder.AddBool("async_writes", o.asyncWriters != nil)
+	encoder.AddInt("sinks", len(o.sinks))
+	encoder.AddBool("console_journal", o.consoleJournal != nil)
 	encoder.AddString("log_level_switcher_server_auto_start", ptrBoolToString(o.logLevelSwitcherServerAutoStart))
 	encoder.AddString("log_level_switcher_server_listen_addr", o.logLevelSwitcherServerListenAddr)
+	encoder.AddBool("log_level_switcher_server_token", o.logLevelSwitcherServerToken != "")
 	encoder.AddString("pre_spec", ptrLogLevelSpecToString(o.preSpec))
+	encoder.AddString("spec_validation", o.specValidation.String())
+	encoder.AddString("spec_file", o.specFile)
+	encoder.AddDuration("spec_file_poll_interval", o.specFilePollInterval)
+	encoder.AddBool("signal_level_toggle", o.signalLevelToggle)
 	encoder.AddString("report_all_errors", ptrBoolToString(o.reportAllErrors))
 	encoder.AddBool("custom_production_logger_detector", o.productionLoggerDetector != nil)
 
@@ -113,7 +136,8 @@ func WithSwitcherServerAutoStart() InstantiateOption {
 }
 
 // WithLogLevelSwitcherServerListeningAddress configures the listening address the HTTP
-// server log level switcher listens to if started.
+// server log level switcher listens to if started. The address can also be the path of a
+// unix socket prefixed with `unix://`, see `WithLogLevelSwitcherServerUnixSocket`.
 //
 // **Note** This does **not** automatically activate the level switcher server,
 // you still must used `WithSwitcherServerAutoStart` option or start it manually
@@ -124,6 +148,45 @@ func WithLogLevelSwitcherServerListeningAddress(addr string) InstantiateOption {
 	})
 }
 
+// switcherServerTokenEnvVar is the environment variable from which the level switcher server
+// token is read when `WithLogLevelSwitcherServerToken` is not used.
+const switcherServerTokenEnvVar = "LOGGING_SWITCHER_SERVER_TOKEN"
+
+// WithLogLevelSwitcherServerToken protects the HTTP server log level switcher with a bearer
+// token, requests must then carry the `Authorization: Bearer <token>` header. When this option
+// is not used, the token is read from the `LOGGING_SWITCHER_SERVER_TOKEN` environment variable,
+// if set.
+//
+// Then, you can use:
+//
+// curl -H "Authorization: Bearer <token>" -XPUT -d '{"level":"debug","inputs":"true"}' http://localhost:1065
+func WithLogLevelSwitcherServerToken(token string) InstantiateOption {
+	return instantiateFuncOption(func(o *instantiateOptions) {
+		o.logLevelSwitcherServerToken = token
+	})
+}
+
+// WithLogLevelSwitcherServerUnixSocket configures the HTTP server log level switcher to listen
+// on the unix socket at `path` instead of a TCP address, this enables multiple processes on the
+// same host to be controlled independently. If `path` is empty, a per-process path is used,
+// `logging-<pid>.sock` in the temporary directory (usually `/tmp`). The socket is only
+// accessible by the user running the process.
+//
+// Then, you can use:
+//
+// curl --unix-socket /tmp/logging-<pid>.sock -XPUT -d '{"level":"debug","inputs":"true"}' http://localhost
+//
+// **Note** This does **not** automatically activate the level switcher server,
+// you still must used `WithSwitcherServerAutoStart` option or start it manually
+// for this option to have any effect.
+func WithLogLevelSwitcherServerUnixSocket(path string) InstantiateOption {
+	if path == "" {
+		path = defaultSwitcherServerSocketPath()
+	}
+
+	return WithLogLevelSwitcherServerListeningAddress(unixSocketAddrPrefix + path)
+}
+
 // Deprecated: Use `WithLogLevelSwitcherServerListeningAddress` instead
 func WithSwitcherServerListeningAddress(addr string) InstantiateOption {
 	return instantiateFuncOption(func(o *instantiateOptions) {
@@ -187,35 +250,163 @@ func WithDefaultLevel(level zapcore.Level) InstantiateOption {
 // `WithDefaultSpec(...)` with some entries.
 func WithDefaultSpec(specs ...string) InstantiateOption {
 	var logLevelSpec *logLevelSpec
+	var errs SpecErrors
 	if len(specs) > 0 {
-		logLevelSpec = newLogLevelSpec(envGetFromMap(map[string]string{
-			"DLOG": strings.Join(specs, ","),
-		}))
+		logLevelSpec = newEmptyLogLevelSpec()
+		errs = logLevelSpec.fillDlog(LevelSourceWithDefaultSpec, strings.Join(specs, ","))
 	}
 
 	return instantiateFuncOption(func(o *instantiateOptions) {
 		if logLevelSpec != nil {
 			o.preSpec = logLevelSpec
+			o.preSpecErrors = errs
 		}
 	})
 }
 
+// WithSpec is like `WithDefaultSpec` with a spec parsed by `ParseLogLevelSpec`, the malformed
+// elements of `spec` being reported like those of `WithDefaultSpec` (see `WithStrictSpec`).
+func WithSpec(spec Spec) InstantiateOption {
+	if spec.spec == nil {
+		return WithDefaultSpec()
+	}
+
+	return WithDefaultSpec(spec.input)
+}
+
+type specValidation uint8
+
+const (
+	specValidationNone specValidation = iota
+	specValidationWarn
+	specValidationFail
+)
+
+func (v specValidation) String() string {
+	switch v {
+	case specValidationWarn:
+		return "warn"
+	case specValidationFail:
+		return "fail"
+	default:
+		return "none"
+	}
+}
+
+// WithStrictSpec makes `InstantiateLoggers` panic, and `Instantiate` return the `SpecErrors`, if
+// the specs it receives, from the environment or from `WithDefaultSpec`, contain malformed
+// elements (like an unknown level in `DLOG=mypkg=debgu`) or keys that matched no registered
+// logger.
+//
+// By default, such problems are silently ignored.
+func WithStrictSpec() InstantiateOption {
+	return instantiateFuncOption(func(o *instantiateOptions) {
+		o.specValidation = specValidationFail
+	})
+}
+
+// WithSpecWarnings is like `WithStrictSpec` but instead of failing, each problem found is
+// logged as a warning through the root logger.
+func WithSpecWarnings() InstantiateOption {
+	return instantiateFuncOption(func(o *instantiateOptions) {
+		o.specValidation = specValidationWarn
+	})
+}
+
+// WithSpecFile configures the loggers' level from the spec contained in the file at `path`, the
+// file is read when loggers are instantiated and then polled (see `WithSpecFilePollInterval`)
+// so that the spec is re-applied each time the file's content changes.
+//
+// Files with a `.yaml`, `.yml` or `.json` extension must contain a map of `<key>: <level>`
+// entries, any other file must use the same syntax as the `DLOG` environment variable where
+// new lines can be used to separate elements and lines starting with `#` are ignored.
+//
+// The spec file has precedence over all the other sources, once a logger is no longer matched
+// by the file, it's restored to the level it had before the file affected it. When the file
+// cannot be read or contains invalid elements, the last valid spec remains in effect.
+func WithSpecFile(path string) InstantiateOption {
+	if path == "" {
+		panic(fmt.Errorf("the receive spec file path is empty, this is not accepted as a valid option"))
+	}
+
+	return instantiateFuncOption(func(o *instantiateOptions) {
+		o.specFile = path
+	})
+}
+
+// WithSpecFilePollInterval configures how often the file given to `WithSpecFile` is checked
+// for changes, defaults to 5 seconds.
+func WithSpecFilePollInterval(interval time.Duration) InstantiateOption {
+	if interval <= 0 {
+		panic(fmt.Errorf("the spec file poll interval must be positive, got %s", interval))
+	}
+
+	return instantiateFuncOption(func(o *instantiateOptions) {
+		o.specFilePollInterval = interval
+	})
+}
+
+// WithSignalLevelToggle installs signal handlers to change the level of the loggers sharing the
+// root logger's short name (or of all loggers if there is no root logger) without the level
+// switcher server: each `SIGUSR1` steps them up one verbosity level (info → debug → trace) and
+// `SIGUSR2` resets them to the level they had once instantiated.
+//
+// Signals are only supported on Unix platforms, the option is ignored with a warning elsewhere.
+func WithSignalLevelToggle() InstantiateOption {
+	return instantiateFuncOption(func(o *instantiateOptions) {
+		o.signalLevelToggle = true
+	})
+}
+
 // WithOutputToFile configures the loggers to write to the `logFile` received in the argument
 // in **addition** to the console logging that is performed automatically.
 //
-// The actual format of the log file will a JSON fromat `stackdriver` format which is ultimately
-// just a JSON logger with formatted in such that is ingestible by Stackdriver compatible
-// ingestor(s) (todays know as Google Cloud Operations).
-func WithOutputToFile(logFile string) InstantiateOption {
+// The log file is written in zap's production JSON format by default, use `WithFormat` (or the
+// `LOG_FILE_FORMAT` environment variable) to change it.
+//
+// The file grows forever unless rotation options are passed, like `FileMaxSize`,
+// `FileMaxBackups`, `FileMaxAge`, `FileCompress`, `FileRotateOnStartup` or `FileReopenOnSIGHUP`
+// when the rotation is performed by `logrotate`.
+func WithOutputToFile(logFile string, options ...FileOption) InstantiateOption {
 	if logFile == "" {
 		panic(fmt.Errorf("the receive log file value is empty, this is not accepted as a valid option"))
 	}
 
+	// The file is shared by all the loggers instantiated with the option
+	file := newSharedLogFile(logFile, options...)
+
 	return instantiateFuncOption(func(o *instantiateOptions) {
-		o.logToFile = logFile
+		o.logFile = file
 	})
 }
 
+// WithAsyncWrites configures the loggers to queue the entries written to the console and to the
+// log file, a background goroutine writing them in batches, so that a slow terminal or a stalled
+// pipe doesn't block the logging calls.
+//
+// The queue holds 1024 entries and is written every second by default, see `AsyncQueueSize` and
+// `AsyncFlushInterval`. When the queue is full, the logging call blocks until it has room unless
+// `AsyncDropPolicy` says otherwise, `Instance.DroppedLogEntries` then reports how many entries were
+// dropped.
+//
+// The queue is written when a logger is synced and when a DPanic, Panic or Fatal entry is logged,
+// as well as when the `Instance` is closed, following writes being synchronous.
+func WithAsyncWrites(options ...AsyncOption) InstantiateOption {
+	// The writers are shared by all the loggers instantiated with the option
+	writers := newAsyncWriters(options...)
+
+	return instantiateFuncOption(func(o *instantiateOptions) {
+		o.asyncWriters = writers
+	})
+}
+
+// WithCaller configures the loggers to annotate each entry with the file, line and function of
+// the logging call, written as `caller` by the text and JSON formats, as `log.origin.*` in ECS,
+// `code.*` attributes in OpenTelemetry and `CODE_FILE`, `CODE_LINE` and `CODE_FUNC` in journald.
+func WithCaller() InstantiateOption {
+	return withZapOption(zap.AddCaller())
+}
+
 // WithConsoleToStdout configures the console to log to `stdout` instead of the default
 // which is to log to `stderr`.
 func WithConsoleToStdout() InstantiateOption {
@@ -313,6 +504,16 @@ func InstantiateLoggers(opts ...InstantiateOption) {
 	instantiateLoggers(globalRegistry, os.Getenv, newInstantiateOptions(opts...))
 }
 
+// Instantiate is like `InstantiateLoggers` but returns an `Instance` that can be closed to stop
+// the background activities started while instantiating the loggers, like the level switcher
+// server, and flush the loggers. Contrary to `InstantiateLoggers` which only logs it, a failure to
+// start the level switcher server is returned as an error, the loggers are still instantiated in
+// that case. With `WithStrictSpec`, the problems found in the specs are returned as `SpecErrors`
+// instead of panicking, no instance is returned then.
+func Instantiate(opts ...InstantiateOption) (*Instance, error) {
+	return instantiate(globalRegistry, os.Getenv, newInstantiateOptions(opts...))
+}
+
 // ApplicationLogger calls `RootLogger` followed by `InstantiateLoggers`. It's a one-liner when
 // creating scripts to both create the root logger and instantiate all loggers.
 //
@@ -350,25 +551,77 @@ func rootLogger(registry *registry, shortName string, packageID string, opts ...
 }
 
 func instantiateLoggers(registry *registry, envGet func(string) string, options instantiateOptions) {
+	// The instance is never closed, the background activities run for the whole process lifetime
+	_, err := instantiate(registry, envGet, options)
+
+	var specErrs SpecErrors
+	if errors.As(err, &specErrs) {
+		panic(fmt.Errorf("invalid log level spec: %s", specErrs))
+	}
+
+	if err != nil {
+		dbgZlog.Warn("failed starting atomic level switcher", zap.Error(err), zap.String("listen_addr", options.logLevelSwitcherServerListenAddr))
+	}
+}
+
+func instantiate(registry *registry, envGet func(string) string, options instantiateOptions) (*Instance, error) {
 	dbgZlog.Info("instantiate loggers invoked", zap.Object("options", options))
 
+	instance := newInstance(registry)
+	formatProblems := options.applyFormatEnv(envGet)
+
+	if rootEntry := registry.getRootEntry(); rootEntry != nil {
+		options.appName = rootEntry.shortName
+	}
+
+	// Under systemd, the console is written to the journal directly unless a format is requested
+	if options.consoleFormat == "" {
+		consoleOutput, _ := options.consoleOutputFile()
+		options.consoleJournal = journalConsole(consoleOutput, envGet)
+	}
+
+	// The entries that can't be exported to an OTLP collector are written to the console
+	for _, exporter := range options.otlpExporters() {
+		exporter.configure(options.newFallbackCore(), options.appName)
+	}
+
 	// We override the factory function so that we use "our" options which are those passed by the
 	// developer.
-	registry.factory = func(name string, level zap.AtomicLevel) *zap.Logger {
-		return newLogger(registry.dbgLogger, name, level, &options)
-	}
+	registry.setFactory(func(shortName string, packageID string, level zap.AtomicLevel) *zap.Logger {
+		return newLogger(registry.dbgLogger, shortName, packageID, level, &options)
+	})
 
 	dbgZlog.Info("creating all loggers")
 	registry.forAllEntries(func(entry *registryEntry) {
 		registry.createLoggerForEntry(entry)
 	})
 
+	envSpec, envSpecErrors := parseLogLevelSpec(envGet)
+
+	var specFile *specFileWatcher
+	var fileSpec *logLevelSpec
+	var fileSpecErrors SpecErrors
+	var fileErr error
+	if options.specFile != "" {
+		specFile = newSpecFileWatcher(registry, options.specFile, options.specFilePollInterval)
+		fileSpec, fileSpecErrors, _, fileErr = specFile.read()
+	}
+
+	var specProblems SpecErrors
+	if options.specValidation != specValidationNone {
+		specProblems = validateSpecs(registry, []*logLevelSpec{options.preSpec, envSpec, fileSpec}, options.preSpecErrors, envSpecErrors, fileSpecErrors)
+		if len(specProblems) > 0 && options.specValidation == specValidationFail {
+			return nil, specProblems
+		}
+	}
+
+	rootEntry := registry.getRootEntry()
 	rootLoggerAffectedByUser := false
 
 	if options.defaultLevel != nil {
 		dbgZlog.Info("override level from default level option")
 		registry.forAllEntries(func(entry *registryEntry) {
-			registry.setLevelForEntry(entry, *options.defaultLevel, false)
+			registry.setLevelForEntry(entry, *options.defaultLevel, false, LevelOrigin{Source: LevelSourceWithDefaultLevel})
 		})
 
 		// Root logger is always affected by this, since the default level affects all loggers
@@ -377,71 +630,168 @@ func instantiateLoggers(registry *registry, envGet func(string) string, options
 
 	// We first override the level based on pre spec passed by the developer on the InstantiateLoggers, if set
 	if options.preSpec != nil {
-		dbgZlog.Info("override level from pre spec option", zap.Bool("has_root_logger", registry.rootEntry != nil))
+		dbgZlog.Info("override level from pre spec option", zap.Bool("has_root_logger", rootEntry != nil))
 
-		registry.forAllEntriesMatchingSpec(options.preSpec, func(entry *registryEntry, level zapcore.Level, trace bool) {
-			if registry.rootEntry != nil && entry.packageID == registry.rootEntry.packageID {
-				dbgZlog.Info("root logger affected by env", zap.Stringer("root", registry.rootEntry))
+		registry.forAllEntriesMatchingSpec(options.preSpec, func(entry *registryEntry, spec *levelSpec) {
+			if rootEntry != nil && entry.packageID == rootEntry.packageID {
+				dbgZlog.Info("root logger affected by env", zap.Stringer("root", rootEntry))
 				rootLoggerAffectedByUser = true
 			}
 
-			dbgZlog.Debug("setting logger entry matching from pre spec with level logger", zap.Stringer("to_level", level), zap.Stringer("entry", entry))
-			registry.setLevelForEntry(entry, level, trace)
+			dbgZlog.Debug("setting logger entry matching from pre spec with level logger", zap.Stringer("to_level", spec.level), zap.Stringer("entry", entry))
+			registry.setLevelForEntryFromSpec(entry, spec)
 		})
 	}
 
 	// We then override the level based on the spec extracted from the environment
-	dbgZlog.Info("override level from env specification", zap.Bool("has_root_logger", registry.rootEntry != nil))
+	dbgZlog.Info("override level from env specification", zap.Bool("has_root_logger", rootEntry != nil))
 
-	logLevelSpec := newLogLevelSpec(envGet)
-	registry.forAllEntriesMatchingSpec(logLevelSpec, func(entry *registryEntry, level zapcore.Level, trace bool) {
-		if registry.rootEntry != nil && entry.packageID == registry.rootEntry.packageID {
-			dbgZlog.Info("root logger affected by env", zap.Stringer("root", registry.rootEntry))
+	registry.forAllEntriesMatchingSpec(envSpec, func(entry *registryEntry, spec *levelSpec) {
+		if rootEntry != nil && entry.packageID == rootEntry.packageID {
+			dbgZlog.Info("root logger affected by env", zap.Stringer("root", rootEntry))
 			rootLoggerAffectedByUser = true
 		}
 
-		dbgZlog.Debug("setting logger entry matching from env with level logger", zap.Stringer("to_level", level), zap.Stringer("entry", entry))
-		registry.setLevelForEntry(entry, level, trace)
+		dbgZlog.Debug("setting logger entry matching from env with level logger", zap.Stringer("to_level", spec.level), zap.Stringer("entry", entry))
+		registry.setLevelForEntryFromSpec(entry, spec)
 	})
 
 	rootLogger := zap.NewNop()
 
-	if registry.rootEntry != nil {
-		rootLogger = registry.rootEntry.logPtr
+	if rootEntry != nil {
+		rootLogger = rootEntry.logger
 
 		if !rootLoggerAffectedByUser {
 			// No environment affected the root logger, let's force INFO to be used for all entries with the same shortName (usually a common project)
-			for _, entry := range registry.entriesByShortName[registry.rootEntry.shortName] {
+			for _, entry := range registry.entriesForShortName(rootEntry.shortName) {
 				dbgZlog.Debug("setting logger by short name with info logger because the root logger has not been affected by any env",
 					zap.Stringer("to_level", zap.InfoLevel),
 					zap.Stringer("entry", entry),
 				)
 
-				registry.setLevelForEntry(entry, zapcore.InfoLevel, false)
+				registry.setLevelForEntry(entry, zapcore.InfoLevel, false, LevelOrigin{Source: LevelSourceRootLoggerFallback})
+			}
+		}
+	}
+
+	// The logger reporting to the developer what the library does, created only when needed
+	var libraryLogger *zap.Logger
+	getLibraryLogger := func() *zap.Logger {
+		if libraryLogger == nil {
+			libraryLogger = rootLogger
+			if rootEntry == nil {
+				libraryLogger = registry.getFactory()("logging", "", zap.NewAtomicLevelAt(zapcore.InfoLevel))
 			}
 		}
+
+		return libraryLogger
+	}
+
+	for _, problem := range formatProblems {
+		getLibraryLogger().Warn("ignoring invalid log format", zap.Error(problem))
+	}
+
+	for _, problem := range specProblems {
+		getLibraryLogger().Warn("ignoring invalid log level spec element", zap.Error(problem))
+	}
+
+	// The spec file is applied last since it has precedence over everything else
+	if specFile != nil {
+		specFile.logger = getLibraryLogger()
+
+		switch {
+		case fileErr != nil:
+			specFile.logger.Warn("unable to read log level spec file, ignoring it until it becomes readable", zap.String("path", specFile.path), zap.Error(fileErr))
+		case len(fileSpecErrors) > 0:
+			specFile.logger.Warn("invalid log level spec file, ignoring it until it becomes valid", zap.String("path", specFile.path), zap.Error(fileSpecErrors))
+		default:
+			specFile.apply(fileSpec)
+		}
+
+		instance.onClose(specFile.watch())
+	}
+
+	registry.recordBaselines()
+
+	if options.signalLevelToggle {
+		instance.onClose(installSignalLevelToggle(newLevelToggler(registry, getLibraryLogger())))
+	}
+
+	if options.asyncWriters != nil {
+		instance.asyncWriters = options.asyncWriters
+		instance.onClose(options.asyncWriters.stop)
+	}
+
+	for _, exporter := range options.otlpExporters() {
+		instance.otlpExporters = append(instance.otlpExporters, exporter)
+		instance.onClose(exporter.stop)
+	}
+
+	instance.logFiles = options.logFiles()
+	for _, logFile := range options.logFiles() {
+		if !logFile.options.reopenOnSIGHUP {
+			continue
+		}
+
+		if _, err := logFile.open(); err == nil {
+			instance.onClose(installReopenOnSIGHUP(logFile.file, getLibraryLogger()))
+		}
 	}
 
 	// Hijack standard Golang `log` and redirects it to our common logger
 	zap.RedirectStdLogAt(rootLogger, zap.DebugLevel)
 
 	if options.logLevelSwitcherServerAutoStart != nil && *options.logLevelSwitcherServerAutoStart {
-		go func() {
-			listenAddr := options.logLevelSwitcherServerListenAddr
-			dbgZlog.Info("starting atomic level switcher", zap.String("listen_addr", listenAddr))
+		token := options.logLevelSwitcherServerToken
+		if token == "" {
+			token = envGet(switcherServerTokenEnvVar)
+		}
 
-			handler := &switcherServerHandler{registry: registry}
-			if err := http.ListenAndServe(listenAddr, handler); err != nil {
-				dbgZlog.Warn("failed starting atomic level switcher", zap.Error(err), zap.String("listen_addr", listenAddr))
-			}
-		}()
+		listenAddr := options.logLevelSwitcherServerListenAddr
+		dbgZlog.Info("starting atomic level switcher", zap.String("listen_addr", listenAddr))
+
+		if err := instance.startSwitcherServer(listenAddr, registry.LevelSwitcherHandler(SwitcherServerToken(token))); err != nil {
+			registry.dumpRegistryToLogger()
+			return instance, fmt.Errorf("start level switcher server on %q: %w", listenAddr, err)
+		}
 	}
 
 	registry.dumpRegistryToLogger()
+
+	return instance, nil
 }
 
-func newLogger(dbgLogger *zap.Logger, name string, level zap.AtomicLevel, opts *instantiateOptions) *zap.Logger {
-	logger, err := maybeNewLogger(dbgLogger, name, level, opts)
+// validateSpecs returns the parsing errors received along with an error for each key of the specs
+// that matched no registered logger.
+func validateSpecs(registry *registry, specs []*logLevelSpec, parseErrors ...SpecErrors) (out SpecErrors) {
+	for _, errs := range parseErrors {
+		out = append(out, errs...)
+	}
+
+	for _, spec := range specs {
+		if spec == nil {
+			continue
+		}
+
+		for _, unmatched := range registry.unmatchedSpecs(spec) {
+			out = append(out, &SpecError{Source: unmatched.source, Offset: unmatched.offset, Element: unmatched.key, Reason: "matched no registered logger"})
+		}
+	}
+
+	return
+}
+
+func joinErrors(errs []error) string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func newLogger(dbgLogger *zap.Logger, name string, packageID string, level zap.AtomicLevel, opts *instantiateOptions) *zap.Logger {
+	logger, err := maybeNewLogger(dbgLogger, name, packageID, level, opts)
 	if err != nil {
 		panic(fmt.Errorf("unable to create logger (in production? %t): %w", opts.isProductionEnvironment(), err))
 	}
@@ -449,7 +799,7 @@ func newLogger(dbgLogger *zap.Logger, name string, level zap.AtomicLevel, opts *
 	return logger
 }
 
-func maybeNewLogger(dbgLogger *zap.Logger, name string, level zap.AtomicLevel, opts *instantiateOptions) (logger *zap.Logger, err error) {
+func maybeNewLogger(dbgLogger *zap.Logger, name string, packageID string, level zap.AtomicLevel, opts *instantiateOptions) (logger *zap.Logger, err error) {
 	if name != "" {
 		dbgLogger = dbgLogger.With(zap.String("for", name))
 	}
@@ -460,76 +810,148 @@ func maybeNewLogger(dbgLogger *zap.Logger, name string, level zap.AtomicLevel, o
 		}
 	}()
 
-	consoleOutput := os.Stderr
-	if opts.consoleOutput != nil {
-		switch *opts.consoleOutput {
-		case "stderr":
-			consoleOutput = os.Stderr
-		case "stdout":
-			consoleOutput = os.Stdout
-		}
-	}
+	consoleOutput, consoleName := opts.consoleOutputFile()
 
 	zapOptions := opts.zapOptions
 	isTTY := terminal.IsTerminal(int(consoleOutput.Fd()))
 	logConsoleWriter := zapcore.Lock(consoleOutput)
 
 	var fileSyncer zapcore.WriteSyncer
-	if opts.logToFile != "" {
-		dbgLogger.Debug("creating file syncer", zap.String("log_file", opts.logToFile))
+	if opts.logFile != nil {
+		dbgLogger.Debug("creating file syncer", zap.String("log_file", opts.logFile.path))
 
 		var err error
-		fileSyncer, err = createLogFileWriter(opts.logToFile)
+		fileSyncer, err = opts.logFile.open()
 		if err != nil {
 			return nil, fmt.Errorf("create file syncer: %w", err)
 		}
 	}
 
-	var consoleCore zapcore.Core
-	if opts.isProductionEnvironment() || opts.forceProductionLogger {
-		reportAllErrors := opts.reportAllErrors != nil
-		serviceName := opts.serviceName
-
-		if reportAllErrors && opts.serviceName != nil {
-			zapOptions = append(zapOptions, zapdriver.WrapCore(zapdriver.ReportAllErrors(true), zapdriver.ServiceName(*serviceName)))
-		} else if reportAllErrors {
-			zapOptions = append(zapOptions, zapdriver.WrapCore(zapdriver.ReportAllErrors(true)))
-		} else if opts.serviceName != nil {
-			zapOptions = append(zapOptions, zapdriver.WrapCore(zapdriver.ServiceName(*serviceName)))
+	var journalWriter zapcore.WriteSyncer
+	if opts.consoleJournal != nil {
+		journalWriter = opts.consoleJournal
+	}
+
+	if opts.asyncWriters != nil {
+		logConsoleWriter = opts.asyncWriters.wrap(consoleName, logConsoleWriter)
+		if fileSyncer != nil {
+			fileSyncer = opts.asyncWriters.wrap("file", fileSyncer)
+		}
+		if journalWriter != nil {
+			journalWriter = opts.asyncWriters.wrap("journal", journalWriter)
 		}
+	}
 
-		consoleCore = zapcore.NewCore(zapcore.NewJSONEncoder(zapdriver.NewProductionEncoderConfig()), logConsoleWriter, level)
+	var consoleCore zapcore.Core
+	if journalWriter != nil {
+		consoleCore = newJournaldCore(journalWriter, level, opts.appName)
 	} else {
-		consoleCore = zapcore.NewCore(NewEncoder(1, isTTY), logConsoleWriter, level)
+		consoleCore = opts.newFormatCore(opts.consoleFormatOrDefault(), isTTY, logConsoleWriter, level)
+	}
+
+	sinkCores, err := opts.newSinkCores(name, packageID, level)
+	if err != nil {
+		return nil, err
 	}
 
-	if fileSyncer == nil {
-		dbgLogger.Debug("returning only console syncer into a standard core, as there is no file syncer defined")
+	if fileSyncer == nil && len(sinkCores) == 0 {
+		dbgLogger.Debug("returning only console syncer into a standard core, as there is no file syncer nor sink defined")
 		return zap.New(consoleCore, zapOptions...), nil
 	}
 
-	// FIXME: The log to file is always performed in JSON, we should enable some configuration for it to tweak
-	// its output format, but it's not clear how this would look like, probably would come with some "general"
-	// formatting option that would enabled changing for example the console output format itself.
-	dbgLogger.Debug("merging console and file syncer into a tee core")
-	fileCore := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), fileSyncer, level)
-	teeCore := zapcore.NewTee(consoleCore, fileCore)
+	cores := []zapcore.Core{consoleCore}
+	if fileSyncer != nil {
+		cores = append(cores, opts.newFormatCore(opts.fileFormatOrDefault(), false, fileSyncer, level))
+	}
+
+	dbgLogger.Debug("merging console, file syncer and sinks into a tee core", zap.Int("sink_count", len(sinkCores)))
+	teeCore := zapcore.NewTee(append(cores, sinkCores...)...)
 
 	return zap.New(teeCore, zapOptions...), nil
 }
 
-func createLogFileWriter(logFile string) (zapcore.WriteSyncer, error) {
+// newSinkCores creates the cores of the sinks selecting the logger.
+func (o *instantiateOptions) newSinkCores(shortName string, packageID string, level zap.AtomicLevel) ([]zapcore.Core, error) {
+	var cores []zapcore.Core
+	for _, sink := range o.sinks {
+		if !sink.selector.matches(shortName, packageID) {
+			continue
+		}
+
+		// The exporter queues the entries itself, the console's level tells which entries the
+		// fallback must write
+		if sink.output.otlp != nil {
+			cores = append(cores, newOTLPCore(sink.output.otlp, sink.levelEnabler(level), level))
+			continue
+		}
+
+		writer, err := sink.output.open()
+		if err != nil {
+			return nil, fmt.Errorf("create sink %q syncer: %w", sink.name, err)
+		}
+
+		// The ring buffer is in memory, it never blocks
+		if o.asyncWriters != nil && sink.output.ring == nil {
+			writer = o.asyncWriters.wrap("sink/"+sink.name, writer)
+		}
+
+		if sink.output.journal != nil {
+			cores = append(cores, newJournaldCore(writer, sink.levelEnabler(level), o.appName))
+			continue
+		}
+
+		if sink.output.syslog != nil {
+			options := sink.output.syslog.options
+			if options.appName == "" {
+				options.appName = o.appName
+			}
+
+			cores = append(cores, newSyslogCore(writer, sink.levelEnabler(level), options))
+			continue
+		}
+
+		cores = append(cores, o.newFormatCore(sink.format, false, writer, sink.levelEnabler(level)))
+	}
+
+	return cores, nil
+}
+
+// consoleOutputFile returns the file the console writes to along with its name.
+func (o *instantiateOptions) consoleOutputFile() (*os.File, string) {
+	if o.consoleOutput != nil && *o.consoleOutput == "stdout" {
+		return os.Stdout, "stdout"
+	}
+
+	return os.Stderr, "stderr"
+}
+
+// logFiles returns the log file and the files of the sinks.
+func (o *instantiateOptions) logFiles() (out []*sharedLogFile) {
+	if o.logFile != nil {
+		out = append(out, o.logFile)
+	}
+
+	for _, sink := range o.sinks {
+		if sink.output.file != nil {
+			out = append(out, sink.output.file)
+		}
+	}
+
+	return
+}
+
+func createLogFileWriter(logFile string) (zapcore.WriteSyncer, func(), error) {
 	err := os.MkdirAll(filepath.Dir(logFile), 0755)
 	if err != nil && !os.IsExist(err) {
-		return nil, fmt.Errorf("make directories for log file %q: %w", logFile, err)
+		return nil, nil, fmt.Errorf("make directories for log file %q: %w", logFile, err)
 	}
 
-	writer, _, err := zap.Open(logFile)
+	writer, closer, err := zap.Open(logFile)
 	if err != nil {
-		return nil, fmt.Errorf("open log file %q: %w", logFile, err)
+		return nil, nil, fmt.Errorf("open log file %q: %w", logFile, err)
 	}
 
-	return writer, err
+	return writer, closer, err
 }
 
 type Tracer interface {
@@ -537,7 +959,7 @@ type Tracer interface {
 }
 
 type boolTracer struct {
-	value *bool
+	value *atomic.Bool
 }
 
 func (t boolTracer) Enabled() bool {
@@ -545,7 +967,7 @@ func (t boolTracer) Enabled() bool {
 		return false
 	}
 
-	return *t.value
+	return t.value.Load()
 }
 
 func ptrBool(value bool) *bool                    { return &value }
@@ -594,3 +1016,25 @@ func ptrLogLevelSpecToString(value *logLevelSpec) string {
 
 	return (*value).String()
 }
+
+// otlpExporters returns the exporters of the OTLP sinks.
+func (o *instantiateOptions) otlpExporters() (out []*otlpExporter) {
+	for _, sink := range o.sinks {
+		if sink.output.otlp != nil {
+			out = append(out, sink.output.otlp)
+		}
+	}
+
+	return
+}
+
+// newFallbackCore returns a core writing all the entries it receives to the console, bypassing
+// the asynchronous writes.
+func (o *instantiateOptions) newFallbackCore() zapcore.Core {
+	if o.consoleJournal != nil {
+		return newJournaldCore(o.consoleJournal, zapcore.DebugLevel, o.appName)
+	}
+
+	consoleOutput, _ := o.consoleOutputFile()
+	return o.newFormatCore(o.consoleFormatOrDefault(), terminal.IsTerminal(int(consoleOutput.Fd())), zapcore.Lock(consoleOutput), zapcore.DebugLevel)
+}
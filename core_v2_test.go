@@ -1,10 +1,12 @@
 package logging
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -166,9 +168,7 @@ func TestAppAndPkgLogger_EnvDebugAllPkgViaLegacyRegister(t *testing.T) {
 	})
 
 	registry := newRegistry("test", dbgZlog)
-	pkgLogger := zap.NewNop()
-
-	register(registry, "com/lib", pkgLogger)
+	pkgLogger := register(registry, "com/lib", zap.NewNop())
 	appLogger, appTracer := applicationLogger(registry, env, "test", "com/test")
 
 	assertLevelEnabled(t, pkgLogger, zap.DebugLevel)
@@ -223,6 +223,196 @@ func TestAppAndPkgLogger_LoggerDefaultLevel_EnvDlogOverrideLibToSilence(t *testi
 	assertLevelAndTraceEnabled(t, appLogger, zap.InfoLevel, appTracer, traceShouldBeDisabled)
 }
 
+func TestAppAndPkgLogger_LoggerDefaultLevel_EnvDlogOverrideLibToOff(t *testing.T) {
+	env := fakeEnv(map[string]string{
+		"DLOG": "com/lib=off",
+	})
+
+	registry := newRegistry("test", dbgZlog)
+	pkgLogger, pkgTracer := packageLogger(registry, "lib", "com/lib", LoggerDefaultLevel(zap.InfoLevel))
+	appLogger, appTracer := applicationLogger(registry, env, "test", "com/test")
+
+	assertLevelAndTraceEnabled(t, pkgLogger, offLevel, pkgTracer, traceShouldBeDisabled)
+	assert.False(t, pkgLogger.Core().Enabled(zap.FatalLevel), "fatal level should be disabled when logger is off")
+	assertLevelAndTraceEnabled(t, appLogger, zap.InfoLevel, appTracer, traceShouldBeDisabled)
+}
+
+func TestAppAndPkgLogger_EnvDebugWithDenyElement(t *testing.T) {
+	env := fakeEnv(map[string]string{
+		"DEBUG": ".*,-com/lib/noisy",
+	})
+
+	registry := newRegistry("test", dbgZlog)
+	pkgLogger, pkgTracer := packageLogger(registry, "lib", "com/lib")
+	noisyLogger, noisyTracer := packageLogger(registry, "noisy", "com/lib/noisy")
+	appLogger, appTracer := applicationLogger(registry, env, "test", "com/test")
+
+	assertLevelAndTraceEnabled(t, pkgLogger, zap.DebugLevel, pkgTracer, traceShouldBeDisabled)
+	assertLevelAndTraceEnabled(t, noisyLogger, zap.ErrorLevel, noisyTracer, traceShouldBeDisabled)
+	assertLevelAndTraceEnabled(t, appLogger, zap.DebugLevel, appTracer, traceShouldBeDisabled)
+}
+
+func TestAppAndPkgLogger_EnvDenyElementDoesNotAffectOtherSources(t *testing.T) {
+	env := fakeEnv(map[string]string{
+		"DEBUG": ".*,-noisy",
+		"TRACE": "noisy",
+		"DLOG":  "com/.*=info,-com/lib",
+	})
+
+	registry := newRegistry("test", dbgZlog)
+	pkgLogger, pkgTracer := packageLogger(registry, "lib", "com/lib")
+	noisyLogger, noisyTracer := packageLogger(registry, "noisy", "com/lib/noisy")
+	appLogger, appTracer := applicationLogger(registry, env, "test", "com/test")
+
+	assertLevelAndTraceEnabled(t, pkgLogger, zap.DebugLevel, pkgTracer, traceShouldBeDisabled)
+	assertLevelAndTraceEnabled(t, noisyLogger, zap.InfoLevel, noisyTracer, traceShouldBeDisabled)
+	assertLevelAndTraceEnabled(t, appLogger, zap.InfoLevel, appTracer, traceShouldBeDisabled)
+}
+
+func TestInstantiate_Explain(t *testing.T) {
+	env := fakeEnv(map[string]string{
+		"DEBUG": "lib",
+		"DLOG":  "com/lib/.*=info,com/other=warn",
+	})
+
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	packageLogger(registry, "lib", "com/lib/sub")
+	packageLogger(registry, "other", "com/other")
+	packageLogger(registry, "untouched", "com/untouched", LoggerDefaultLevel(zap.WarnLevel))
+	applicationLogger(registry, env, "test", "com/test", WithDefaultSpec("other=debug"))
+
+	tests := []struct {
+		packageID      string
+		expectedLevel  zapcore.Level
+		expectedOrigin LevelOrigin
+	}{
+		{"com/lib", zap.DebugLevel, LevelOrigin{Source: "DEBUG", Key: "lib", Ordering: 1}},
+		{"com/lib/sub", zap.InfoLevel, LevelOrigin{Source: "DLOG", Key: "com/lib/.*", Ordering: 2}},
+		{"com/other", zap.WarnLevel, LevelOrigin{Source: "DLOG", Key: "com/other", Ordering: 3}},
+		{"com/untouched", zap.WarnLevel, LevelOrigin{Source: LevelSourceLoggerDefaultLevel}},
+		{"com/test", zap.InfoLevel, LevelOrigin{Source: LevelSourceRootLoggerFallback}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.packageID, func(t *testing.T) {
+			explanation, found := registry.Explain(test.packageID)
+			require.True(t, found)
+
+			assert.Equal(t, test.expectedLevel, explanation.Level)
+			assert.Equal(t, test.expectedOrigin, explanation.Origin)
+			assert.Nil(t, explanation.PendingRevert)
+		})
+	}
+
+	_, found := registry.Explain("com/unknown")
+	assert.False(t, found)
+
+	registry.SetLevelWithOptions("other", zap.InfoLevel, false, SetLevelTTL(time.Hour))
+
+	explanation, _ := registry.Explain("com/other")
+	assert.Equal(t, LevelOrigin{Source: LevelSourceSetLevel, Key: "other", TTL: time.Hour}, explanation.Origin)
+	require.NotNil(t, explanation.PendingRevert)
+	assert.Equal(t, zap.WarnLevel, explanation.PendingRevert.Level)
+	assert.Equal(t, LevelOrigin{Source: "DLOG", Key: "com/other", Ordering: 3}, explanation.PendingRevert.Origin)
+
+	registry.RevertLevel("other")
+
+	explanation, _ = registry.Explain("com/other")
+	assert.Equal(t, LevelOrigin{Source: "DLOG", Key: "com/other", Ordering: 3}, explanation.Origin)
+}
+
+func TestInstantiate_StrictSpec(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		opts          []InstantiateOption
+		expectedPanic string
+	}{
+		{"valid", map[string]string{"DLOG": "com/lib=debug", "DEBUG": "lib"}, nil, ""},
+		{"unknown level", map[string]string{"DLOG": "com/lib=debgu"}, nil, `invalid log level spec: DLOG: invalid element "com/lib=debgu" at offset 0: unknown level "debgu"`},
+		{"unmatched key", map[string]string{"DEBUG": "lib,unknown"}, nil, `invalid log level spec: DEBUG: invalid element "unknown" at offset 4: matched no registered logger`},
+		{"unmatched default spec key", nil, []InstantiateOption{WithDefaultSpec("com/unknown=info")}, `invalid log level spec: with_default_spec: invalid element "com/unknown" at offset 0: matched no registered logger`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registry := newRegistry("test", dbgZlog)
+			packageLogger(registry, "lib", "com/lib")
+
+			instantiate := func() {
+				applicationLogger(registry, fakeEnv(test.env), "test", "com/test", append(test.opts, WithStrictSpec())...)
+			}
+
+			if test.expectedPanic == "" {
+				assert.NotPanics(t, instantiate)
+			} else {
+				assert.PanicsWithError(t, test.expectedPanic, instantiate)
+			}
+		})
+	}
+}
+
+func TestInstance_StrictSpecReturnsErrors(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	rootLogger(registry, "test", "com/test")
+
+	var instance *Instance
+	var err error
+	require.NotPanics(t, func() {
+		instance, err = instantiate(registry, fakeEnv(map[string]string{"DLOG": "com/lib=debgu"}), newInstantiateOptions(WithStrictSpec(), WithProductionDetector(func() bool { return false })))
+	})
+
+	assert.Nil(t, instance)
+
+	var specErrs SpecErrors
+	require.True(t, errors.As(err, &specErrs), "expected SpecErrors, got %T", err)
+	require.Len(t, specErrs, 1)
+	assert.Equal(t, "DLOG", specErrs[0].Source)
+	assert.Equal(t, "com/lib=debgu", specErrs[0].Element)
+}
+
+func TestInstantiate_WithSpec(t *testing.T) {
+	spec, err := ParseLogLevelSpec("com/lib=debug,com/other=debgu")
+	require.Error(t, err)
+
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	packageLogger(registry, "other", "com/other")
+	applicationLogger(registry, noEnv, "test", "com/test", WithSpec(spec), WithProductionDetector(func() bool { return false }))
+
+	assert.Equal(t, zapcore.DebugLevel, levelOf(registry, "com/lib"))
+	assert.Equal(t, zapcore.ErrorLevel, levelOf(registry, "com/other"))
+
+	explanation, _ := registry.Explain("com/lib")
+	assert.Equal(t, LevelOrigin{Source: LevelSourceWithDefaultSpec, Key: "com/lib", Ordering: 1}, explanation.Origin)
+
+	// The malformed elements are reported like those of `WithDefaultSpec`
+	assert.PanicsWithError(t, `invalid log level spec: with_default_spec: invalid element "com/other=debgu" at offset 14: unknown level "debgu"`, func() {
+		instantiateLoggers(registry, noEnv, newInstantiateOptions(WithSpec(spec), WithStrictSpec()))
+	})
+}
+
+func TestInstantiate_SpecWarnings(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	testingCore := newTestingCore()
+
+	packageLogger(registry, "lib", "com/lib")
+	applicationLogger(registry, fakeEnv(map[string]string{"DLOG": "com/lib=debug,unknown=info,com/test=debgu"}), "test", "com/test", WithSpecWarnings(), WithProductionDetector(func() bool { return false }), testingOptions(testingCore))
+
+	entry, _ := registry.entryByPackageID("com/lib")
+	assert.Equal(t, zap.DebugLevel, entry.atomicLevel.Level(), "valid elements should still be applied")
+
+	var warnings []string
+	for _, entry := range testingCore.checkedEntries {
+		if entry.Level == zap.WarnLevel {
+			warnings = append(warnings, entry.Message)
+		}
+	}
+	assert.Equal(t, []string{"ignoring invalid log level spec element", "ignoring invalid log level spec element"}, warnings)
+}
+
 func TestLogger_CustomizedNamePerLogger(t *testing.T) {
 	env := fakeEnv(map[string]string{
 		"DEBUG": "*",
@@ -282,8 +472,8 @@ func TestLogger_SetLevelForEntry_EnvDebugStar(t *testing.T) {
 	overrideEnv := fakeEnv(map[string]string{
 		"DEBUG": "*",
 	})
-	registry.forAllEntriesMatchingSpec(newLogLevelSpec(overrideEnv), func(entry *registryEntry, level zapcore.Level, trace bool) {
-		registry.setLevelForEntry(entry, level, trace)
+	registry.forAllEntriesMatchingSpec(newLogLevelSpec(overrideEnv), func(entry *registryEntry, specForKey *levelSpec) {
+		registry.setLevelForEntryFromSpec(entry, specForKey)
 	})
 
 	assertLevelAndTraceEnabled(t, pkgLogger, zap.DebugLevel, pkgTracer, traceShouldBeDisabled)
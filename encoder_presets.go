@@ -0,0 +1,253 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// ecsVersion is the Elastic Common Schema version the `NewECSEncoder` output conforms to.
+const ecsVersion = "1.6.0"
+
+// The keys of the fields holding the trace and span IDs of the current operation, they are moved
+// to the `trace.id` and `span.id` fields in ECS and to the top-level `traceId` and `spanId` in
+// OpenTelemetry.
+const (
+	traceIDKey = "trace_id"
+	spanIDKey  = "span_id"
+)
+
+// errorKey is the key used by `zap.Error`, its fields are mapped to `error.*` in ECS and to
+// `exception.*` attributes in OpenTelemetry.
+const errorKey = "error"
+
+// otelSeverityNumbers maps the levels to the OpenTelemetry log data model severity numbers, each
+// level having its own number, DPanic, Panic and Fatal being in the FATAL range.
+var otelSeverityNumbers = map[zapcore.Level]int{
+	zapcore.DebugLevel:  5,
+	zapcore.InfoLevel:   9,
+	zapcore.WarnLevel:   13,
+	zapcore.ErrorLevel:  17,
+	zapcore.DPanicLevel: 21,
+	zapcore.PanicLevel:  22,
+	zapcore.FatalLevel:  23,
+}
+
+// otelSeverity returns the OpenTelemetry severity number and text of `level`, the levels below
+// debug, used for trace entries, being TRACE.
+func otelSeverity(level zapcore.Level) (int, string) {
+	if level < zapcore.DebugLevel {
+		return 1, "TRACE"
+	}
+
+	if number, found := otelSeverityNumbers[level]; found {
+		return number, level.CapitalString()
+	}
+
+	return otelSeverityNumbers[zapcore.FatalLevel], level.CapitalString()
+}
+
+// utcRFC3339MilliTimeEncoder writes the time in UTC with a millisecond precision, the format
+// expected in ECS `@timestamp`.
+func utcRFC3339MilliTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(t.UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+}
+
+// NewECSEncoder creates a JSON encoder following the Elastic Common Schema, the entry is written
+// as `@timestamp`, `log.level`, `log.logger`, `message` and `log.origin.file.name`,
+// `log.origin.file.line`, `log.origin.function` for the caller. The `zap.Error` field becomes
+// `error.message` and `error.type`, the stack trace `error.stack_trace` and the `trace_id` and
+// `span_id` fields `trace.id` and `span.id`.
+func NewECSEncoder() zapcore.Encoder {
+	return &ecsEncoder{Encoder: zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		TimeKey:        "@timestamp",
+		LevelKey:       "log.level",
+		NameKey:        "log.logger",
+		MessageKey:     "message",
+		StacktraceKey:  "error.stack_trace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     utcRFC3339MilliTimeEncoder,
+		EncodeDuration: zapcore.NanosDurationEncoder,
+	})}
+}
+
+type ecsEncoder struct {
+	zapcore.Encoder
+}
+
+// AddString renames the fields added through `With`, an error added that way has lost its type
+// and only its message is kept.
+func (enc *ecsEncoder) AddString(key, val string) {
+	switch key {
+	case traceIDKey:
+		key = "trace.id"
+	case spanIDKey:
+		key = "span.id"
+	case errorKey:
+		key = "error.message"
+	}
+
+	enc.Encoder.AddString(key, val)
+}
+
+func (enc *ecsEncoder) Clone() zapcore.Encoder {
+	return &ecsEncoder{Encoder: enc.Encoder.Clone()}
+}
+
+func (enc *ecsEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	ecsFields := make([]zapcore.Field, 0, len(fields)+4)
+	ecsFields = append(ecsFields, zap.String("ecs.version", ecsVersion))
+
+	if ent.Caller.Defined {
+		ecsFields = append(ecsFields, zap.String("log.origin.file.name", ent.Caller.File), zap.Int("log.origin.file.line", ent.Caller.Line))
+		if ent.Caller.Function != "" {
+			ecsFields = append(ecsFields, zap.String("log.origin.function", ent.Caller.Function))
+		}
+	}
+
+	for _, field := range fields {
+		switch {
+		case isErrorField(field):
+			err := field.Interface.(error)
+			ecsFields = append(ecsFields, zap.String("error.message", err.Error()), zap.String("error.type", fmt.Sprintf("%T", err)))
+		case field.Key == traceIDKey && field.Type == zapcore.StringType:
+			ecsFields = append(ecsFields, zap.String("trace.id", field.String))
+		case field.Key == spanIDKey && field.Type == zapcore.StringType:
+			ecsFields = append(ecsFields, zap.String("span.id", field.String))
+		default:
+			ecsFields = append(ecsFields, field)
+		}
+	}
+
+	return enc.Encoder.EncodeEntry(ent, ecsFields)
+}
+
+// NewOTelEncoder creates a JSON encoder following the OpenTelemetry log data model, the entry is
+// written as `timestamp`, `severityText`, `severityNumber`, `body` and `instrumentationScope.name`
+// (the logger name) while the fields go in `attributes`, along with the caller as `code.filepath`,
+// `code.lineno` and `code.function`, the `zap.Error` field as `exception.message` and
+// `exception.type` and the stack trace as `exception.stacktrace`. The `trace_id` and `span_id`
+// fields become the top-level `traceId` and `spanId`.
+func NewOTelEncoder() zapcore.Encoder {
+	return &otelEncoder{
+		Encoder: zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+			EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+			EncodeDuration: zapcore.StringDurationEncoder,
+		}),
+		record: zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+			TimeKey:    "timestamp",
+			LevelKey:   "severityText",
+			MessageKey: "body",
+			LineEnding: zapcore.DefaultLineEnding,
+			EncodeLevel: func(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+				_, text := otelSeverity(level)
+				enc.AppendString(text)
+			},
+			EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+				zapcore.RFC3339NanoTimeEncoder(t.UTC(), enc)
+			},
+		}),
+	}
+}
+
+// otelEncoder accumulates the fields added through `With` in the embedded encoder which only
+// renders the attributes, the log record itself being rendered by `record`.
+type otelEncoder struct {
+	zapcore.Encoder
+
+	record  zapcore.Encoder
+	traceID string
+	spanID  string
+}
+
+func (enc *otelEncoder) AddString(key, val string) {
+	switch key {
+	case traceIDKey:
+		enc.traceID = val
+	case spanIDKey:
+		enc.spanID = val
+	case errorKey:
+		enc.Encoder.AddString("exception.message", val)
+	default:
+		enc.Encoder.AddString(key, val)
+	}
+}
+
+func (enc *otelEncoder) Clone() zapcore.Encoder {
+	return &otelEncoder{Encoder: enc.Encoder.Clone(), record: enc.record, traceID: enc.traceID, spanID: enc.spanID}
+}
+
+func (enc *otelEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	traceID, spanID := enc.traceID, enc.spanID
+
+	attributeFields := make([]zapcore.Field, 0, len(fields)+4)
+	if ent.Caller.Defined {
+		attributeFields = append(attributeFields, zap.String("code.filepath", ent.Caller.File), zap.Int("code.lineno", ent.Caller.Line))
+		if ent.Caller.Function != "" {
+			attributeFields = append(attributeFields, zap.String("code.function", ent.Caller.Function))
+		}
+	}
+
+	for _, field := range fields {
+		switch {
+		case isErrorField(field):
+			err := field.Interface.(error)
+			attributeFields = append(attributeFields, zap.String("exception.message", err.Error()), zap.String("exception.type", fmt.Sprintf("%T", err)))
+		case field.Key == traceIDKey && field.Type == zapcore.StringType:
+			traceID = field.String
+		case field.Key == spanIDKey && field.Type == zapcore.StringType:
+			spanID = field.String
+		default:
+			attributeFields = append(attributeFields, field)
+		}
+	}
+
+	if ent.Stack != "" {
+		attributeFields = append(attributeFields, zap.String("exception.stacktrace", ent.Stack))
+	}
+
+	// All the keys of the attributes encoder are empty, it renders only the fields as an object
+	attributes, err := enc.Encoder.EncodeEntry(zapcore.Entry{}, attributeFields)
+	if err != nil {
+		return nil, err
+	}
+	defer attributes.Free()
+
+	severityNumber, _ := otelSeverity(ent.Level)
+	recordFields := []zapcore.Field{zap.Int("severityNumber", severityNumber)}
+	if traceID != "" {
+		recordFields = append(recordFields, zap.String("traceId", traceID))
+	}
+	if spanID != "" {
+		recordFields = append(recordFields, zap.String("spanId", spanID))
+	}
+	if ent.LoggerName != "" {
+		recordFields = append(recordFields, zap.Object("instrumentationScope", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("name", ent.LoggerName)
+			return nil
+		})))
+	}
+	recordFields = append(recordFields, zap.Reflect("attributes", json.RawMessage(bytes.TrimSpace(attributes.Bytes()))))
+
+	record := ent
+	record.LoggerName = ""
+	record.Caller = zapcore.EntryCaller{}
+	record.Stack = ""
+
+	return enc.record.EncodeEntry(record, recordFields)
+}
+
+func isErrorField(field zapcore.Field) bool {
+	if field.Key != errorKey || field.Type != zapcore.ErrorType {
+		return false
+	}
+
+	_, ok := field.Interface.(error)
+	return ok
+}
@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var presetTestEntry = zapcore.Entry{
+	Level:      zapcore.ErrorLevel,
+	Time:       time.Date(2021, 3, 4, 5, 6, 7, 8000000, time.FixedZone("EST", -5*3600)),
+	LoggerName: "app",
+	Caller:     zapcore.EntryCaller{Defined: true, File: "github.com/acme/app/main.go", Line: 12, Function: "main.run"},
+	Message:    "request failed",
+	Stack:      "main.run\n\tmain.go:12",
+}
+
+func TestECSEncoder(t *testing.T) {
+	encoder := NewECSEncoder()
+	encoder.AddString("trace_id", "4bf92f3577b34da6")
+	encoder.AddString("user", "john")
+
+	buffer, err := encoder.Clone().EncodeEntry(presetTestEntry, []zapcore.Field{zap.Error(errors.New("timeout")), zap.String("span_id", "00f067aa"), zap.Duration("elapsed", time.Millisecond)})
+	require.NoError(t, err)
+	defer buffer.Free()
+
+	assert.JSONEq(t, `{
+		"@timestamp": "2021-03-04T10:06:07.008Z",
+		"log.level": "error",
+		"log.logger": "app",
+		"message": "request failed",
+		"trace.id": "4bf92f3577b34da6",
+		"user": "john",
+		"ecs.version": "1.6.0",
+		"log.origin.file.name": "github.com/acme/app/main.go",
+		"log.origin.file.line": 12,
+		"log.origin.function": "main.run",
+		"error.message": "timeout",
+		"error.type": "*errors.errorString",
+		"span.id": "00f067aa",
+		"elapsed": 1000000,
+		"error.stack_trace": "main.run\n\tmain.go:12"
+	}`, buffer.String())
+}
+
+func TestOTelEncoder(t *testing.T) {
+	encoder := NewOTelEncoder()
+	encoder.AddString("trace_id", "4bf92f3577b34da6")
+	encoder.AddString("user", "john")
+
+	buffer, err := encoder.Clone().EncodeEntry(presetTestEntry, []zapcore.Field{zap.Error(errors.New("timeout")), zap.String("span_id", "00f067aa"), zap.Int("attempt", 2)})
+	require.NoError(t, err)
+	defer buffer.Free()
+
+	assert.JSONEq(t, `{
+		"timestamp": "2021-03-04T10:06:07.008Z",
+		"severityText": "ERROR",
+		"severityNumber": 17,
+		"body": "request failed",
+		"traceId": "4bf92f3577b34da6",
+		"spanId": "00f067aa",
+		"instrumentationScope": {"name": "app"},
+		"attributes": {
+			"user": "john",
+			"code.filepath": "github.com/acme/app/main.go",
+			"code.lineno": 12,
+			"code.function": "main.run",
+			"exception.message": "timeout",
+			"exception.type": "*errors.errorString",
+			"attempt": 2,
+			"exception.stacktrace": "main.run\n\tmain.go:12"
+		}
+	}`, buffer.String())
+}
+
+func TestOTelEncoder_Minimal(t *testing.T) {
+	buffer, err := NewOTelEncoder().EncodeEntry(zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Unix(0, 0), Message: "hello"}, nil)
+	require.NoError(t, err)
+	defer buffer.Free()
+
+	assert.Equal(t, `{"severityText":"INFO","timestamp":"1970-01-01T00:00:00Z","body":"hello","severityNumber":9,"attributes":{}}`+"\n", buffer.String())
+}
+
+func TestOTelSeverity(t *testing.T) {
+	tests := []struct {
+		level  zapcore.Level
+		number int
+		text   string
+	}{
+		{zapcore.DebugLevel - 1, 1, "TRACE"},
+		{zapcore.DebugLevel, 5, "DEBUG"},
+		{zapcore.InfoLevel, 9, "INFO"},
+		{zapcore.WarnLevel, 13, "WARN"},
+		{zapcore.ErrorLevel, 17, "ERROR"},
+		{zapcore.DPanicLevel, 21, "DPANIC"},
+		{zapcore.PanicLevel, 22, "PANIC"},
+		{zapcore.FatalLevel, 23, "FATAL"},
+	}
+
+	for _, test := range tests {
+		number, text := otelSeverity(test.level)
+		assert.Equal(t, test.number, number, test.level.String())
+		assert.Equal(t, test.text, text, test.level.String())
+	}
+}
+
+func TestInstantiate_PresetCaller(t *testing.T) {
+	for _, format := range []Format{FormatECS, FormatOTel} {
+		t.Run(string(format), func(t *testing.T) {
+			logFile := filepath.Join(t.TempDir(), "app.log")
+
+			registry := newRegistry("test", dbgZlog)
+			logger, _ := applicationLogger(registry, noEnv, "app", "github.com/acme/app", WithOutputToFile(logFile), WithFormat(FormatText, format), WithCaller(), WithProductionDetector(func() bool { return false }))
+			logger.Info("hello")
+
+			lines := readLogLines(t, logFile)
+			require.Len(t, lines, 1)
+
+			var record map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+
+			if format == FormatOTel {
+				record = record["attributes"].(map[string]interface{})
+				assert.Contains(t, record["code.filepath"], "encoder_presets_test.go")
+				assert.NotZero(t, record["code.lineno"])
+				assert.Contains(t, record["code.function"], "TestInstantiate_PresetCaller")
+				return
+			}
+
+			assert.Contains(t, record["log.origin.file.name"], "encoder_presets_test.go")
+			assert.NotZero(t, record["log.origin.file.line"])
+			assert.Contains(t, record["log.origin.function"], "TestInstantiate_PresetCaller")
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blendle/zapdriver"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Format is the output format of the console or file loggers, see `WithFormat`.
+type Format string
+
+const (
+	// FormatStackdriver is a JSON format ingestible by Stackdriver (Google Cloud Operations), it's
+	// the default console format in production.
+	FormatStackdriver Format = "stackdriver"
+
+	// FormatJSON is zap's production JSON format, it's the default format of the log file.
+	FormatJSON Format = "json"
+
+	// FormatText is the developer friendly format of `NewEncoder`, colored when writing to a
+	// terminal, it's the default console format outside production.
+	FormatText Format = "text"
+
+	// FormatLogfmt writes `key=value` lines, see `NewLogfmtEncoder`.
+	FormatLogfmt Format = "logfmt"
+
+	// FormatECS is a JSON format following the Elastic Common Schema, see `NewECSEncoder`.
+	FormatECS Format = "ecs"
+
+	// FormatOTel is a JSON format following the OpenTelemetry log data model, see `NewOTelEncoder`.
+	FormatOTel Format = "otel"
+)
+
+// The environment variables overriding the formats configured through `WithFormat`.
+const (
+	formatEnvVar     = "LOG_FORMAT"
+	fileFormatEnvVar = "LOG_FILE_FORMAT"
+)
+
+var formats = []Format{FormatStackdriver, FormatJSON, FormatText, FormatLogfmt, FormatECS, FormatOTel}
+
+func parseFormat(in string) (Format, error) {
+	format := Format(strings.ToLower(strings.TrimSpace(in)))
+	for _, candidate := range formats {
+		if format == candidate {
+			return format, nil
+		}
+	}
+
+	names := make([]string, len(formats))
+	for i, candidate := range formats {
+		names[i] = string(candidate)
+	}
+
+	return "", fmt.Errorf("unknown log format %q, valid formats are %s", in, strings.Join(names, ", "))
+}
+
+func (f Format) newEncoder(enableColors bool) zapcore.Encoder {
+	switch f {
+	case FormatStackdriver:
+		return zapcore.NewJSONEncoder(zapdriver.NewProductionEncoderConfig())
+	case FormatJSON:
+		return zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	case FormatText:
+		return NewEncoder(1, enableColors)
+	case FormatLogfmt:
+		return NewLogfmtEncoder(newLogfmtEncoderConfig())
+	case FormatECS:
+		return NewECSEncoder()
+	case FormatOTel:
+		return NewOTelEncoder()
+	}
+
+	panic(fmt.Errorf("unhandled log format %q", f))
+}
+
+// WithFormat configures the format of the console and of the log file (see `WithOutputToFile`)
+// among `FormatStackdriver`, `FormatJSON`, `FormatText`, `FormatLogfmt`, `FormatECS` and
+// `FormatOTel`, an empty format keeps the default one which is `FormatStackdriver` in production
+// and `FormatText` otherwise for the console and `FormatJSON` for the file.
+//
+// The `LOG_FORMAT` and `LOG_FILE_FORMAT` environment variables, when set, take precedence over
+// this option so that the format can be changed without rebuilding, an invalid value is reported
+// as a warning and ignored.
+func WithFormat(console Format, file Format) InstantiateOption {
+	for _, format := range []*Format{&console, &file} {
+		if *format == "" {
+			continue
+		}
+
+		parsed, err := parseFormat(string(*format))
+		if err != nil {
+			panic(err)
+		}
+
+		*format = parsed
+	}
+
+	return instantiateFuncOption(func(o *instantiateOptions) {
+		if console != "" {
+			o.consoleFormat = console
+		}
+
+		if file != "" {
+			o.fileFormat = file
+		}
+	})
+}
+
+// applyFormatEnv overrides the formats with the ones defined through the environment, returning
+// an error for each invalid value which leaves the format untouched.
+func (o *instantiateOptions) applyFormatEnv(envGet func(string) string) (problems []error) {
+	for _, override := range []struct {
+		envVar string
+		format *Format
+	}{{formatEnvVar, &o.consoleFormat}, {fileFormatEnvVar, &o.fileFormat}} {
+		envVar, format := override.envVar, override.format
+
+		value := envGet(envVar)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := parseFormat(value)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", envVar, err))
+			continue
+		}
+
+		*format = parsed
+	}
+
+	return
+}
+
+func (o *instantiateOptions) consoleFormatOrDefault() Format {
+	if o.consoleFormat != "" {
+		return o.consoleFormat
+	}
+
+	if o.isProductionEnvironment() || o.forceProductionLogger {
+		return FormatStackdriver
+	}
+
+	return FormatText
+}
+
+func (o *instantiateOptions) fileFormatOrDefault() Format {
+	if o.fileFormat != "" {
+		return o.fileFormat
+	}
+
+	return FormatJSON
+}
+
+// newFormatCore creates the core writing in the given format, Stackdriver cores being wrapped to
+// report errors and the service name as configured.
+func (o *instantiateOptions) newFormatCore(format Format, enableColors bool, writer zapcore.WriteSyncer, level zapcore.LevelEnabler) zapcore.Core {
+	core := zapcore.NewCore(format.newEncoder(enableColors), writer, level)
+	if format != FormatStackdriver {
+		return core
+	}
+
+	reportAllErrors := o.reportAllErrors != nil
+	serviceName := o.serviceName
+
+	var wrapOption zap.Option
+	if reportAllErrors && serviceName != nil {
+		wrapOption = zapdriver.WrapCore(zapdriver.ReportAllErrors(true), zapdriver.ServiceName(*serviceName))
+	} else if reportAllErrors {
+		wrapOption = zapdriver.WrapCore(zapdriver.ReportAllErrors(true))
+	} else if serviceName != nil {
+		wrapOption = zapdriver.WrapCore(zapdriver.ServiceName(*serviceName))
+	} else {
+		return core
+	}
+
+	return zap.New(core, wrapOption).Core()
+}
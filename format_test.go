@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in          string
+		expected    Format
+		expectedErr string
+	}{
+		{"stackdriver", FormatStackdriver, ""},
+		{"JSON", FormatJSON, ""},
+		{" text ", FormatText, ""},
+		{"xml", "", `unknown log format "xml", valid formats are stackdriver, json, text, logfmt, ecs, otel`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			format, err := parseFormat(test.in)
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, format)
+		})
+	}
+}
+
+func TestInstantiate_Format(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []InstantiateOption
+		env      map[string]string
+		expected []string
+	}{
+		{"default", nil, nil, []string{`"level":"info"`, `"msg":"hello"`}},
+		{"stackdriver", []InstantiateOption{WithFormat("", FormatStackdriver)}, nil, []string{`"severity":"INFO"`, `"message":"hello"`}},
+		{"text", []InstantiateOption{WithFormat("", FormatText)}, nil, []string{"INFO (test) hello"}},
+		{"logfmt", []InstantiateOption{WithFormat("", FormatLogfmt)}, nil, []string{"level=info logger=test msg=hello\n"}},
+		{"env overrides option", []InstantiateOption{WithFormat("", FormatText)}, map[string]string{"LOG_FILE_FORMAT": "stackdriver"}, []string{`"message":"hello"`}},
+		{"invalid env ignored", []InstantiateOption{WithFormat("", FormatText)}, map[string]string{"LOG_FILE_FORMAT": "xml"}, []string{"WARN (test) ignoring invalid log format", "INFO (test) hello"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			options := append([]InstantiateOption{WithOutputToFile(path), WithProductionDetector(func() bool { return false })}, test.options...)
+
+			registry := newRegistry("test", dbgZlog)
+			logger, _ := applicationLogger(registry, fakeEnv(test.env), "test", "com/test", options...)
+			logger.Info("hello")
+			logger.Sync()
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			for _, expected := range test.expected {
+				assert.Contains(t, string(content), expected)
+			}
+		})
+	}
+}
+
+func TestWithFormat_Invalid(t *testing.T) {
+	assert.PanicsWithError(t, `unknown log format "xml", valid formats are stackdriver, json, text, logfmt, ecs, otel`, func() {
+		WithFormat("xml", "")
+	})
+}
@@ -8,6 +8,8 @@ require (
 	github.com/mitchellh/go-testing-interface v1.14.1
 	github.com/stretchr/testify v1.7.0
 	github.com/test-go/testify v1.1.4
+	go.uber.org/atomic v1.7.0
 	go.uber.org/zap v1.21.0
 	golang.org/x/crypto v0.0.0-20220214200702-86341886e292
+	gopkg.in/yaml.v3 v3.0.1
 )
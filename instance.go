@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// Instance represents the loggers instantiated by `Instantiate` along with the background
+// activities started for them, like the level switcher server. Use `Close` to stop them.
+type Instance struct {
+	registry *registry
+
+	server     *http.Server
+	serverAddr net.Addr
+
+	asyncWriters  *asyncWriters
+	otlpExporters []*otlpExporter
+
+	// logFiles are the log file and the files of the sinks, closed once the loggers are flushed
+	logFiles []*sharedLogFile
+
+	closers   []func()
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newInstance(registry *registry) *Instance {
+	return &Instance{registry: registry}
+}
+
+// onClose registers a function stopping a background activity when the instance is closed.
+func (i *Instance) onClose(closer func()) {
+	i.closers = append(i.closers, closer)
+}
+
+func (i *Instance) startSwitcherServer(listenAddr string, handler http.Handler) error {
+	listener, err := listenSwitcherServer(listenAddr)
+	if err != nil {
+		return err
+	}
+
+	i.server = &http.Server{Handler: handler}
+	i.serverAddr = listener.Addr()
+
+	go func() {
+		if err := i.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			dbgZlog.Warn("atomic level switcher stopped unexpectedly", zap.Error(err), zap.String("listen_addr", listenAddr))
+		}
+	}()
+
+	return nil
+}
+
+// LevelSwitcherServerAddr returns the address the level switcher server listens on, which is
+// useful when listening on port 0, or an empty string if the server was not started.
+func (i *Instance) LevelSwitcherServerAddr() string {
+	if i.serverAddr == nil {
+		return ""
+	}
+
+	return i.serverAddr.String()
+}
+
+// DroppedLogEntries returns the number of entries dropped because the queue of the asynchronous
+// writes was full, see `WithAsyncWrites` and `AsyncDropPolicy`, because the asynchronous writes
+// failed, or because they couldn't be exported to an OTLP collector, see `OTLPWithoutFallback`.
+func (i *Instance) DroppedLogEntries() uint64 {
+	var dropped uint64
+	if i.asyncWriters != nil {
+		dropped = i.asyncWriters.dropped()
+	}
+
+	for _, exporter := range i.otlpExporters {
+		dropped += exporter.dropped.Load()
+	}
+
+	return dropped
+}
+
+// Close stops the level switcher server, waiting for in-flight requests until `ctx` is done, as
+// well as the other background activities like the spec file polling and the signal handlers,
+// then flushes all the registered loggers and closes the log file and the files of the sinks.
+// Loggers remain usable once the instance is closed, writing to the closed files fails however.
+//
+// Calling `Close` more than once returns the result of the first call.
+func (i *Instance) Close(ctx context.Context) error {
+	i.closeOnce.Do(func() {
+		var errs []error
+
+		if i.server != nil {
+			if err := i.server.Shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("shutdown level switcher server: %w", err))
+			}
+		}
+
+		for _, closer := range i.closers {
+			closer()
+		}
+
+		i.registry.forAllEntries(func(entry *registryEntry) {
+			if err := entry.logger.Sync(); err != nil && !isIgnorableSyncError(err) {
+				errs = append(errs, fmt.Errorf("sync logger %q: %w", entry.packageID, err))
+			}
+		})
+
+		for _, logFile := range i.logFiles {
+			if err := logFile.close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if len(errs) > 0 {
+			i.closeErr = errors.New(joinErrors(errs))
+		}
+	})
+
+	return i.closeErr
+}
+
+// isIgnorableSyncError returns true for the errors returned when syncing a console output like
+// `stderr` attached to a terminal, which cannot be synced.
+func isIgnorableSyncError(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY)
+}
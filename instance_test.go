@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestInstance_CloseStopsSwitcherServer(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	rootLogger(registry, "test", "com/test")
+
+	instance, err := instantiate(registry, noEnv, newInstantiateOptions(WithLogLevelSwitcherServerAutoStart(), WithLogLevelSwitcherServerListeningAddress("127.0.0.1:0")))
+	require.NoError(t, err)
+	require.NotEmpty(t, instance.LevelSwitcherServerAddr())
+
+	url := "http://" + instance.LevelSwitcherServerAddr() + "/"
+	response, err := http.Get(url)
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, 200, response.StatusCode)
+
+	require.NoError(t, instance.Close(context.Background()))
+	require.NoError(t, instance.Close(context.Background()), "closing twice should be a no-op")
+
+	_, err = http.Get(url)
+	assert.Error(t, err)
+}
+
+func TestInstance_SwitcherServerBindFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	registry := newRegistry("test", dbgZlog)
+	appLogger, appTracer := rootLogger(registry, "test", "com/test")
+
+	_, err = instantiate(registry, noEnv, newInstantiateOptions(WithLogLevelSwitcherServerAutoStart(), WithLogLevelSwitcherServerListeningAddress(listener.Addr().String())))
+	assert.Error(t, err)
+
+	assertLevelAndTraceEnabled(t, appLogger, zap.InfoLevel, appTracer, traceShouldBeDisabled)
+}
+
+func TestInstance_CloseStopsSpecFileWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "levels")
+	writeSpecFile(t, path, "com/lib=info")
+
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	rootLogger(registry, "test", "com/test")
+
+	instance, err := instantiate(registry, noEnv, newInstantiateOptions(WithSpecFile(path), WithSpecFilePollInterval(5*time.Millisecond), WithProductionDetector(func() bool { return false })))
+	require.NoError(t, err)
+	require.Equal(t, zap.InfoLevel, levelOf(registry, "com/lib"))
+
+	require.NoError(t, instance.Close(context.Background()))
+
+	writeSpecFile(t, path, "com/lib=debug")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, zap.InfoLevel, levelOf(registry, "com/lib"))
+}
+
+func TestInstance_CloseClosesLogFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	registry := newRegistry("test", dbgZlog)
+	logger, _ := rootLogger(registry, "test", "com/test")
+
+	instance, err := instantiate(registry, noEnv, newInstantiateOptions(
+		WithOutputToFile(filepath.Join(dir, "app.log"), FileMaxSize(1024*1024), FileCompress()),
+		WithSink("plain", SinkToFile(filepath.Join(dir, "sink.log"))),
+		WithProductionDetector(func() bool { return false }),
+	))
+	require.NoError(t, err)
+
+	logger.Info("hello")
+
+	require.Len(t, instance.logFiles, 2)
+	rotating := instance.logFiles[0].file
+	require.NotNil(t, rotating)
+
+	require.NoError(t, instance.Close(context.Background()))
+
+	for _, logFile := range instance.logFiles {
+		assert.False(t, logFile.opened, logFile.path)
+	}
+
+	_, err = rotating.Write([]byte("after close\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,210 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// journalSocketPath is where systemd-journald receives the entries in its native protocol.
+var journalSocketPath = "/run/systemd/journal/socket"
+
+// journalStreamEnvVar is set by systemd to the device and inode numbers of the stdout/stderr
+// stream connected to the journal, see `systemd.exec(5)`.
+const journalStreamEnvVar = "JOURNAL_STREAM"
+
+// SinkToJournald writes the sink's entries to systemd-journald using its native protocol, the
+// level becoming the `PRIORITY`, the logger name the `SYSLOG_IDENTIFIER`, the caller
+// `CODE_FILE`, `CODE_LINE` and `CODE_FUNC` and the fields uppercase journal fields. Only
+// supported on Linux, writing fails elsewhere.
+//
+// The sink writes its own format, `SinkFormat` is ignored.
+func SinkToJournald() SinkOption {
+	writer := newJournalWriter(journalSocketPath)
+
+	return sinkOptionFunc(func(s *sink) {
+		s.output = sinkOutput{journal: writer}
+	})
+}
+
+// journaldCore is a `zapcore.Core` writing each entry as a journal entry in the native protocol,
+// the `writer` receiving one entry per write.
+type journaldCore struct {
+	zapcore.LevelEnabler
+
+	writer zapcore.WriteSyncer
+
+	// identifier is the `SYSLOG_IDENTIFIER` of the entries of loggers without a name
+	identifier string
+	fields     []zapcore.Field
+}
+
+func newJournaldCore(writer zapcore.WriteSyncer, level zapcore.LevelEnabler, identifier string) *journaldCore {
+	if identifier == "" {
+		identifier = filepath.Base(os.Args[0])
+	}
+
+	return &journaldCore{LevelEnabler: level, writer: writer, identifier: identifier}
+}
+
+func (c *journaldCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append([]zapcore.Field(nil), c.fields...), fields...)
+
+	return &clone
+}
+
+func (c *journaldCore) Check(ent zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return checked.AddCore(ent, c)
+	}
+
+	return checked
+}
+
+func (c *journaldCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if _, err := c.writer.Write(c.encode(ent, append(append([]zapcore.Field(nil), c.fields...), fields...))); err != nil {
+		return err
+	}
+
+	// Like zap's own cores, the entries that may end the process are synced right away
+	if ent.Level > zapcore.ErrorLevel {
+		c.Sync()
+	}
+
+	return nil
+}
+
+func (c *journaldCore) Sync() error {
+	return c.writer.Sync()
+}
+
+func (c *journaldCore) encode(ent zapcore.Entry, fields []zapcore.Field) []byte {
+	buf := &bytes.Buffer{}
+
+	identifier := ent.LoggerName
+	if identifier == "" {
+		identifier = c.identifier
+	}
+
+	severity, found := syslogSeverities[ent.Level]
+	if !found {
+		severity = syslogSeverities[zapcore.DebugLevel]
+	}
+
+	appendJournalField(buf, "MESSAGE", ent.Message)
+	appendJournalField(buf, "PRIORITY", strconv.Itoa(severity))
+	appendJournalField(buf, "SYSLOG_IDENTIFIER", identifier)
+
+	if ent.Caller.Defined {
+		appendJournalField(buf, "CODE_FILE", ent.Caller.File)
+		appendJournalField(buf, "CODE_LINE", strconv.Itoa(ent.Caller.Line))
+		if ent.Caller.Function != "" {
+			appendJournalField(buf, "CODE_FUNC", ent.Caller.Function)
+		}
+	}
+
+	if ent.Stack != "" {
+		appendJournalField(buf, "STACKTRACE", ent.Stack)
+	}
+
+	for _, field := range stringFields(fields) {
+		appendJournalField(buf, journalFieldName(field.name), field.value)
+	}
+
+	return buf.Bytes()
+}
+
+// appendJournalField writes `NAME=value\n`, or `NAME\n` followed by the little-endian 64 bits
+// length of the value, the value and `\n` when the value spans multiple lines.
+func appendJournalField(buf *bytes.Buffer, name string, value string) {
+	buf.WriteString(name)
+
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName returns `key` as a valid journal field name, made of at most 64 uppercase
+// letters, digits and underscores and not starting with an underscore, which is reserved to the
+// fields added by journald, nor with a digit.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			name[i] = '_'
+		}
+	}
+
+	out := strings.TrimLeft(string(name), "_")
+	if out == "" || out[0] >= '0' && out[0] <= '9' {
+		out = "FIELD_" + out
+	}
+
+	if len(out) > 64 {
+		out = out[:64]
+	}
+
+	return out
+}
+
+// journalWriter sends each write as a journal entry to the journald socket.
+type journalWriter struct {
+	path string
+
+	lock sync.Mutex
+	conn *net.UnixConn
+}
+
+func newJournalWriter(path string) *journalWriter {
+	return &journalWriter{path: path}
+}
+
+func (w *journalWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err := w.send(p); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Sync does nothing, entries are sent as they are written.
+func (w *journalWriter) Sync() error {
+	return nil
+}
+
+// writesMessages marks the writer as expecting a single entry per write, see `messageWriter`.
+func (w *journalWriter) writesMessages() {}
+
+// journalConsole returns the writer of the journal if the console output is the stream connected
+// to the journal, as advertised by the `JOURNAL_STREAM` environment variable.
+func journalConsole(console *os.File, envGet func(string) string) *journalWriter {
+	journalStream := envGet(journalStreamEnvVar)
+	if journalStream == "" || !isJournalStream(console, journalStream) {
+		return nil
+	}
+
+	if _, err := os.Stat(journalSocketPath); err != nil {
+		return nil
+	}
+
+	return newJournalWriter(journalSocketPath)
+}
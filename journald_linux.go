@@ -0,0 +1,95 @@
+//go:build linux
+// +build linux
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// send writes the entry as a single datagram, or through a file descriptor to a temporary file
+// when the entry is too large for a datagram.
+func (w *journalWriter) send(entry []byte) error {
+	// The socket is not connected so that entries keep flowing when journald restarts
+	if w.conn == nil {
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+		if err != nil {
+			return fmt.Errorf("write to journald: open socket: %w", err)
+		}
+
+		w.conn = conn
+	}
+
+	_, _, err := w.conn.WriteMsgUnix(entry, nil, w.address())
+	if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+		return w.sendThroughFile(entry)
+	}
+
+	if err != nil {
+		return fmt.Errorf("write to journald: %w", err)
+	}
+
+	return nil
+}
+
+func (w *journalWriter) address() *net.UnixAddr {
+	return &net.UnixAddr{Name: w.path, Net: "unixgram"}
+}
+
+func (w *journalWriter) sendThroughFile(entry []byte) error {
+	// The file is only read by journald, /dev/shm keeps it in memory when available
+	file, err := os.CreateTemp("/dev/shm", "journal.*")
+	if err != nil {
+		file, err = os.CreateTemp("", "journal.*")
+		if err != nil {
+			return fmt.Errorf("write to journald: create temporary file: %w", err)
+		}
+	}
+	defer file.Close()
+
+	if err := os.Remove(file.Name()); err != nil {
+		return fmt.Errorf("write to journald: remove temporary file: %w", err)
+	}
+
+	if _, err := file.Write(entry); err != nil {
+		return fmt.Errorf("write to journald: write temporary file: %w", err)
+	}
+
+	if _, _, err := w.conn.WriteMsgUnix(nil, syscall.UnixRights(int(file.Fd())), w.address()); err != nil {
+		return fmt.Errorf("write to journald: send file descriptor: %w", err)
+	}
+
+	return nil
+}
+
+// isJournalStream returns true if `file` is the stream whose `<device>:<inode>` is
+// `journalStream`.
+func isJournalStream(file *os.File, journalStream string) bool {
+	parts := strings.SplitN(journalStream, ":", 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	device, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	inode, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(int(file.Fd()), &stat); err != nil {
+		return false
+	}
+
+	return uint64(stat.Dev) == device && uint64(stat.Ino) == inode
+}
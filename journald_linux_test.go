@@ -0,0 +1,152 @@
+//go:build linux
+// +build linux
+
+package logging
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalWriter(t *testing.T) {
+	path, entries := listenJournal(t)
+	writer := newJournalWriter(path)
+
+	_, err := writer.Write([]byte("MESSAGE=hello\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "MESSAGE=hello\n", receive(t, entries))
+
+	// Too large for a datagram, the entry is sent through a file descriptor
+	large := "MESSAGE=" + strings.Repeat("a", 4*1024*1024) + "\n"
+	_, err = writer.Write([]byte(large))
+	require.NoError(t, err)
+	assert.Equal(t, large, receive(t, entries))
+}
+
+func TestIsJournalStream(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "stream"))
+	require.NoError(t, err)
+	defer file.Close()
+
+	var stat syscall.Stat_t
+	require.NoError(t, syscall.Fstat(int(file.Fd()), &stat))
+
+	assert.True(t, isJournalStream(file, fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)))
+	assert.False(t, isJournalStream(file, fmt.Sprintf("%d:%d", stat.Dev, stat.Ino+1)))
+	assert.False(t, isJournalStream(file, "invalid"))
+}
+
+func TestInstantiate_JournalConsole(t *testing.T) {
+	path, entries := listenJournal(t)
+
+	previousPath := journalSocketPath
+	journalSocketPath = path
+	defer func() { journalSocketPath = previousPath }()
+
+	var stat syscall.Stat_t
+	require.NoError(t, syscall.Fstat(int(os.Stderr.Fd()), &stat))
+	journalStream := fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
+
+	registry := newRegistry("test", dbgZlog)
+	logger, _ := applicationLogger(registry, fakeEnv(map[string]string{"JOURNAL_STREAM": journalStream}), "app", "github.com/acme/app", WithProductionDetector(func() bool { return false }))
+	logger.Info("hello")
+
+	assert.Equal(t, "MESSAGE=hello\nPRIORITY=6\nSYSLOG_IDENTIFIER=app\n", receive(t, entries))
+
+	// An explicit console format disables the journal
+	registry = newRegistry("test", dbgZlog)
+	logger, _ = applicationLogger(registry, fakeEnv(map[string]string{"JOURNAL_STREAM": journalStream, "LOG_FORMAT": "json"}), "app", "github.com/acme/app", WithProductionDetector(func() bool { return false }))
+	logger.Info("hello")
+
+	select {
+	case entry := <-entries:
+		assert.Fail(t, "unexpected journal entry", entry)
+	default:
+	}
+}
+
+func TestInstantiate_JournalCaller(t *testing.T) {
+	path, entries := listenJournal(t)
+
+	previousPath := journalSocketPath
+	journalSocketPath = path
+	defer func() { journalSocketPath = previousPath }()
+
+	registry := newRegistry("test", dbgZlog)
+	logger, _ := applicationLogger(registry, noEnv, "app", "github.com/acme/app", WithSink("journal", SinkToJournald()), WithCaller(), WithProductionDetector(func() bool { return false }))
+	logger.Info("hello")
+
+	fields := map[string]string{}
+	for _, field := range parseJournalEntry(t, []byte(receive(t, entries))) {
+		fields[field[0]] = field[1]
+	}
+
+	assert.Equal(t, "hello", fields["MESSAGE"])
+	assert.True(t, strings.HasSuffix(fields["CODE_FILE"], "journald_linux_test.go"), fields["CODE_FILE"])
+	assert.NotEmpty(t, fields["CODE_LINE"])
+	assert.Contains(t, fields["CODE_FUNC"], "TestInstantiate_JournalCaller")
+}
+
+// listenJournal listens like journald does, reading the entries sent as datagrams or through a
+// file descriptor.
+func listenJournal(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	entries := make(chan string, 10)
+	go func() {
+		buffer := make([]byte, 65536)
+		oob := make([]byte, syscall.CmsgSpace(4))
+
+		for {
+			n, oobn, _, _, err := conn.ReadMsgUnix(buffer, oob)
+			if err != nil {
+				return
+			}
+
+			if oobn == 0 {
+				entries <- string(buffer[:n])
+				continue
+			}
+
+			entries <- readJournalFileDescriptor(oob[:oobn])
+		}
+	}()
+
+	return path, entries
+}
+
+func readJournalFileDescriptor(oob []byte) string {
+	messages, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil || len(messages) != 1 {
+		return fmt.Sprintf("invalid control message: %v", err)
+	}
+
+	fds, err := syscall.ParseUnixRights(&messages[0])
+	if err != nil || len(fds) != 1 {
+		return fmt.Sprintf("invalid unix rights: %v", err)
+	}
+
+	file := os.NewFile(uintptr(fds[0]), "journal")
+	defer file.Close()
+
+	content, err := io.ReadAll(io.NewSectionReader(file, 0, 1<<30))
+	if err != nil {
+		return fmt.Sprintf("unable to read file descriptor: %v", err)
+	}
+
+	return string(content)
+}
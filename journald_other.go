@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package logging
+
+import (
+	"errors"
+	"os"
+)
+
+func (w *journalWriter) send(entry []byte) error {
+	return errors.New("write to journald: journald is only supported on Linux")
+}
+
+func isJournalStream(file *os.File, journalStream string) bool {
+	return false
+}
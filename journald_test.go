@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestJournaldCore(t *testing.T) {
+	out := &messageSyncer{}
+	core := newJournaldCore(out, zapcore.InfoLevel, "app")
+
+	entry := zapcore.Entry{
+		Level:      zapcore.ErrorLevel,
+		Time:       time.Now(),
+		LoggerName: "p2p",
+		Caller:     zapcore.EntryCaller{Defined: true, File: "/src/github.com/acme/p2p/peer.go", Line: 12, Function: "p2p.(*Peer).Run"},
+		Message:    "peer failed",
+		Stack:      "p2p.(*Peer).Run\n\tpeer.go:12",
+	}
+
+	require.NoError(t, core.With([]zapcore.Field{zap.String("peer_id", "abc")}).Write(entry, []zapcore.Field{zap.Int("attempt", 2), zap.String("details", "line 1\nline 2")}))
+	assert.False(t, core.Enabled(zapcore.DebugLevel))
+
+	require.Len(t, out.messages, 1)
+	assert.Equal(t, [][2]string{
+		{"MESSAGE", "peer failed"},
+		{"PRIORITY", "3"},
+		{"SYSLOG_IDENTIFIER", "p2p"},
+		{"CODE_FILE", "/src/github.com/acme/p2p/peer.go"},
+		{"CODE_LINE", "12"},
+		{"CODE_FUNC", "p2p.(*Peer).Run"},
+		{"STACKTRACE", "p2p.(*Peer).Run\n\tpeer.go:12"},
+		{"PEER_ID", "abc"},
+		{"ATTEMPT", "2"},
+		{"DETAILS", "line 1\nline 2"},
+	}, parseJournalEntry(t, []byte(out.messages[0])))
+}
+
+func TestJournaldCore_Identifier(t *testing.T) {
+	out := &messageSyncer{}
+	require.NoError(t, newJournaldCore(out, zapcore.InfoLevel, "app").Write(zapcore.Entry{Level: zapcore.WarnLevel, Message: "hello"}, nil))
+
+	assert.Equal(t, "MESSAGE=hello\nPRIORITY=4\nSYSLOG_IDENTIFIER=app\n", out.messages[0])
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"peer_id":                "PEER_ID",
+		"peer.address":           "PEER_ADDRESS",
+		"_internal":              "INTERNAL",
+		"2fa":                    "FIELD_2FA",
+		"café":                   "CAF__",
+		strings.Repeat("a", 100): strings.Repeat("A", 64),
+	}
+
+	for key, expected := range tests {
+		assert.Equal(t, expected, journalFieldName(key), key)
+	}
+}
+
+// parseJournalEntry decodes the journal native protocol into its fields, in order.
+func parseJournalEntry(t *testing.T, entry []byte) (out [][2]string) {
+	t.Helper()
+
+	for len(entry) > 0 {
+		end := bytes.IndexByte(entry, '\n')
+		require.NotEqual(t, -1, end, "unterminated field")
+
+		line := entry[:end]
+		if equal := bytes.IndexByte(line, '='); equal != -1 {
+			out = append(out, [2]string{string(line[:equal]), string(line[equal+1:])})
+			entry = entry[end+1:]
+			continue
+		}
+
+		entry = entry[end+1:]
+		require.GreaterOrEqual(t, len(entry), 8, "missing value length")
+		length := binary.LittleEndian.Uint64(entry[:8])
+		out = append(out, [2]string{string(line), string(entry[8 : 8+length])})
+		entry = entry[8+length+1:]
+	}
+
+	return
+}
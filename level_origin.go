@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Sources of a logger's level as reported by `LevelOrigin.Source`, all in snake case. Levels
+// coming from the environment have as source the name of the environment variable they were
+// read from, like `DEBUG` or `DLOG`, and levels coming from a spec file the path of the file.
+const (
+	// LevelSourceDefault is the level given to a logger at registration when no
+	// `LoggerDefaultLevel` option was used.
+	LevelSourceDefault = "default"
+
+	// LevelSourceLoggerDefaultLevel is the level given at registration through `LoggerDefaultLevel`.
+	LevelSourceLoggerDefaultLevel = "logger_default_level"
+
+	// LevelSourceWithDefaultLevel is the level applied through the `WithDefaultLevel` option.
+	LevelSourceWithDefaultLevel = "with_default_level"
+
+	// LevelSourceWithDefaultSpec is the level applied through the `WithDefaultSpec` option.
+	LevelSourceWithDefaultSpec = "with_default_spec"
+
+	// LevelSourceRootLoggerFallback is the `INFO` level forced on the loggers sharing the root
+	// logger's short name when nothing configured the root logger.
+	LevelSourceRootLoggerFallback = "root_logger_fallback"
+
+	// LevelSourceSwitcherServer is the level applied through the level switcher server.
+	LevelSourceSwitcherServer = "switcher_server"
+
+	// LevelSourceSetLevel is the level applied through `Registry.SetLevel`.
+	LevelSourceSetLevel = "set_level"
+)
+
+// LevelOrigin describes what applied the current level of a logger.
+type LevelOrigin struct {
+	// Source is what applied the level, one of the `LevelSource...` constants, the name of the
+	// environment variable or the path of the spec file the level was read from.
+	Source string
+
+	// Key is the spec element key that matched the logger, it's empty if the level was not
+	// applied through a spec.
+	Key string
+
+	// Ordering is the ordering of the matching spec element within its spec, a higher ordering
+	// takes precedence over a lower one. It's 0 if the level was not applied through a spec.
+	Ordering int
+
+	// TTL is set when the level is a time-limited override, see `SetLevelTTL`.
+	TTL time.Duration
+}
+
+// LevelExplanation is the current level and trace state of a logger along with their origin,
+// see `LevelRegistry.Explain`.
+type LevelExplanation struct {
+	ShortName string
+	PackageID string
+	Level     zapcore.Level
+	Trace     bool
+	Origin    LevelOrigin
+
+	// PendingRevert is set when the current level is a time-limited override, it's the state
+	// the logger reverts to once the override expires.
+	PendingRevert *LevelRevertExplanation
+}
+
+// LevelRevertExplanation is the state a logger reverts to once a time-limited level override
+// expires.
+type LevelRevertExplanation struct {
+	Level    zapcore.Level
+	Trace    bool
+	Origin   LevelOrigin
+	RevertAt time.Time
+}
+
+// Explain returns why the logger registered in the global registry under `packageID` ended up
+// at its current level, see `LevelRegistry.Explain`.
+func Explain(packageID string) (LevelExplanation, bool) {
+	return globalRegistry.Explain(packageID)
+}
+
+// Explain returns the current level of the logger registered under `packageID` along with what
+// applied it, for example the environment variable and the spec key that matched the logger.
+// It returns false if no logger is registered under `packageID`.
+func (r *registry) Explain(packageID string) (LevelExplanation, bool) {
+	entry, found := r.entryByPackageID(packageID)
+	if !found {
+		return LevelExplanation{}, false
+	}
+
+	return entry.explain(), true
+}
+
+func (e *registryEntry) explain() LevelExplanation {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	explanation := LevelExplanation{
+		ShortName: e.shortName,
+		PackageID: e.packageID,
+		Level:     e.atomicLevel.Level(),
+		Trace:     e.isTraceEnabled(),
+		Origin:    e.origin,
+	}
+
+	if revert := e.pendingRevert; revert != nil {
+		explanation.PendingRevert = &LevelRevertExplanation{
+			Level:    revert.level,
+			Trace:    revert.trace,
+			Origin:   revert.origin,
+			RevertAt: revert.revertAt,
+		}
+	}
+
+	return explanation
+}
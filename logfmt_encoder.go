@@ -0,0 +1,540 @@
+package logging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewLogfmtEncoder creates an encoder writing entries as logfmt `key=value` lines, values are
+// quoted only when they contain spaces, `=`, quotes or control characters. Nested objects (and
+// namespaces) are flattened with their keys joined by a dot, like `parent.child=value`, while
+// arrays are rendered as `[a,b,c]`.
+//
+// The keys and encoders of the entry properties (time, level, logger name, caller, message and
+// stack trace) are taken from the received config, an empty key omits the property.
+func NewLogfmtEncoder(config zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{EncoderConfig: &config, buf: bufferpool.Get()}
+}
+
+// newLogfmtEncoderConfig returns the encoder config used by `FormatLogfmt`.
+func newLogfmtEncoderConfig() zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
+		TimeKey:        "time",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		MessageKey:     "msg",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+}
+
+type logfmtEncoder struct {
+	*zapcore.EncoderConfig
+	buf *buffer.Buffer
+
+	// prefix is prepended to the keys, it holds the open namespaces and objects joined by a dot
+	prefix string
+}
+
+func (enc *logfmtEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	enc.addKey(key)
+	return enc.AppendArray(arr)
+}
+
+func (enc *logfmtEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	prefix := enc.prefix
+	enc.prefix += key + "."
+	defer func() { enc.prefix = prefix }()
+
+	return obj.MarshalLogObject(enc)
+}
+
+func (enc *logfmtEncoder) AddBinary(key string, val []byte) {
+	enc.AddString(key, base64.StdEncoding.EncodeToString(val))
+}
+
+func (enc *logfmtEncoder) AddByteString(key string, val []byte) {
+	enc.addKey(key)
+	enc.AppendByteString(val)
+}
+
+func (enc *logfmtEncoder) AddBool(key string, val bool) {
+	enc.addKey(key)
+	enc.AppendBool(val)
+}
+
+func (enc *logfmtEncoder) AddComplex128(key string, val complex128) {
+	enc.addKey(key)
+	enc.AppendComplex128(val)
+}
+
+func (enc *logfmtEncoder) AddDuration(key string, val time.Duration) {
+	enc.addKey(key)
+	enc.AppendDuration(val)
+}
+
+func (enc *logfmtEncoder) AddFloat64(key string, val float64) {
+	enc.addKey(key)
+	enc.AppendFloat64(val)
+}
+
+func (enc *logfmtEncoder) AddInt64(key string, val int64) {
+	enc.addKey(key)
+	enc.AppendInt64(val)
+}
+
+func (enc *logfmtEncoder) AddReflected(key string, obj interface{}) error {
+	enc.addKey(key)
+	return enc.AppendReflected(obj)
+}
+
+func (enc *logfmtEncoder) OpenNamespace(key string) {
+	enc.prefix += key + "."
+}
+
+func (enc *logfmtEncoder) AddString(key, val string) {
+	enc.addKey(key)
+	enc.AppendString(val)
+}
+
+func (enc *logfmtEncoder) AddTime(key string, val time.Time) {
+	enc.addKey(key)
+	enc.AppendTime(val)
+}
+
+func (enc *logfmtEncoder) AddUint64(key string, val uint64) {
+	enc.addKey(key)
+	enc.AppendUint64(val)
+}
+
+func (enc *logfmtEncoder) AddComplex64(k string, v complex64) { enc.AddComplex128(k, complex128(v)) }
+func (enc *logfmtEncoder) AddFloat32(k string, v float32)     { enc.AddFloat64(k, float64(v)) }
+func (enc *logfmtEncoder) AddInt(k string, v int)             { enc.AddInt64(k, int64(v)) }
+func (enc *logfmtEncoder) AddInt32(k string, v int32)         { enc.AddInt64(k, int64(v)) }
+func (enc *logfmtEncoder) AddInt16(k string, v int16)         { enc.AddInt64(k, int64(v)) }
+func (enc *logfmtEncoder) AddInt8(k string, v int8)           { enc.AddInt64(k, int64(v)) }
+func (enc *logfmtEncoder) AddUint(k string, v uint)           { enc.AddUint64(k, uint64(v)) }
+func (enc *logfmtEncoder) AddUint32(k string, v uint32)       { enc.AddUint64(k, uint64(v)) }
+func (enc *logfmtEncoder) AddUint16(k string, v uint16)       { enc.AddUint64(k, uint64(v)) }
+func (enc *logfmtEncoder) AddUint8(k string, v uint8)         { enc.AddUint64(k, uint64(v)) }
+func (enc *logfmtEncoder) AddUintptr(k string, v uintptr)     { enc.AddUint64(k, uint64(v)) }
+
+// The `Append...` methods write the value of the key just added, they are also what the
+// `Encode...` functions of the config call.
+
+func (enc *logfmtEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	array := &logfmtArrayEncoder{config: enc.EncoderConfig, buf: bufferpool.Get()}
+	defer array.buf.Free()
+
+	array.buf.AppendByte('[')
+	err := arr.MarshalLogArray(array)
+	array.buf.AppendByte(']')
+
+	enc.appendValue(array.buf.String())
+	return err
+}
+
+func (enc *logfmtEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	value, err := logfmtObjectValue(enc.EncoderConfig, obj)
+	enc.appendValue(value)
+	return err
+}
+
+func (enc *logfmtEncoder) AppendBool(val bool)                    { enc.buf.AppendBool(val) }
+func (enc *logfmtEncoder) AppendByteString(val []byte)            { enc.appendValue(string(val)) }
+func (enc *logfmtEncoder) AppendComplex128(val complex128)        { appendLogfmtComplex(enc.buf, val) }
+func (enc *logfmtEncoder) AppendFloat64(val float64)              { appendLogfmtFloat(enc.buf, val, 64) }
+func (enc *logfmtEncoder) AppendFloat32(val float32)              { appendLogfmtFloat(enc.buf, float64(val), 32) }
+func (enc *logfmtEncoder) AppendInt64(val int64)                  { enc.buf.AppendInt(val) }
+func (enc *logfmtEncoder) AppendUint64(val uint64)                { enc.buf.AppendUint(val) }
+func (enc *logfmtEncoder) AppendString(val string)                { enc.appendValue(val) }
+func (enc *logfmtEncoder) AppendComplex64(v complex64)            { enc.AppendComplex128(complex128(v)) }
+func (enc *logfmtEncoder) AppendInt(v int)                        { enc.AppendInt64(int64(v)) }
+func (enc *logfmtEncoder) AppendInt32(v int32)                    { enc.AppendInt64(int64(v)) }
+func (enc *logfmtEncoder) AppendInt16(v int16)                    { enc.AppendInt64(int64(v)) }
+func (enc *logfmtEncoder) AppendInt8(v int8)                      { enc.AppendInt64(int64(v)) }
+func (enc *logfmtEncoder) AppendUint(v uint)                      { enc.AppendUint64(uint64(v)) }
+func (enc *logfmtEncoder) AppendUint32(v uint32)                  { enc.AppendUint64(uint64(v)) }
+func (enc *logfmtEncoder) AppendUint16(v uint16)                  { enc.AppendUint64(uint64(v)) }
+func (enc *logfmtEncoder) AppendUint8(v uint8)                    { enc.AppendUint64(uint64(v)) }
+func (enc *logfmtEncoder) AppendUintptr(v uintptr)                { enc.AppendUint64(uint64(v)) }
+func (enc *logfmtEncoder) AppendTimeLayout(t time.Time, l string) { enc.appendValue(t.Format(l)) }
+
+func (enc *logfmtEncoder) AppendDuration(val time.Duration) {
+	cur := enc.buf.Len()
+	if enc.EncodeDuration != nil {
+		enc.EncodeDuration(val, enc)
+	}
+	if cur == enc.buf.Len() {
+		enc.appendValue(val.String())
+	}
+}
+
+func (enc *logfmtEncoder) AppendTime(val time.Time) {
+	cur := enc.buf.Len()
+	if enc.EncodeTime != nil {
+		enc.EncodeTime(val, enc)
+	}
+	if cur == enc.buf.Len() {
+		enc.appendValue(val.Format(time.RFC3339Nano))
+	}
+}
+
+func (enc *logfmtEncoder) AppendReflected(val interface{}) error {
+	value, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	enc.appendValue(string(value))
+	return nil
+}
+
+func (enc *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := enc.clone()
+	clone.buf.Write(enc.buf.Bytes())
+	return clone
+}
+
+func (enc *logfmtEncoder) clone() *logfmtEncoder {
+	return &logfmtEncoder{EncoderConfig: enc.EncoderConfig, buf: bufferpool.Get(), prefix: enc.prefix}
+}
+
+func (enc *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := enc.clone()
+	final.prefix = ""
+
+	if final.TimeKey != "" {
+		final.AddTime(final.TimeKey, ent.Time)
+	}
+	if final.LevelKey != "" {
+		final.addKey(final.LevelKey)
+		cur := final.buf.Len()
+		if final.EncodeLevel != nil {
+			final.EncodeLevel(ent.Level, final)
+		}
+		if cur == final.buf.Len() {
+			final.AppendString(ent.Level.String())
+		}
+	}
+	if ent.LoggerName != "" && final.NameKey != "" {
+		final.addKey(final.NameKey)
+		cur := final.buf.Len()
+		if final.EncodeName != nil {
+			final.EncodeName(ent.LoggerName, final)
+		}
+		if cur == final.buf.Len() {
+			final.AppendString(ent.LoggerName)
+		}
+	}
+	if ent.Caller.Defined && final.CallerKey != "" {
+		final.addKey(final.CallerKey)
+		cur := final.buf.Len()
+		if final.EncodeCaller != nil {
+			final.EncodeCaller(ent.Caller, final)
+		}
+		if cur == final.buf.Len() {
+			final.AppendString(ent.Caller.String())
+		}
+	}
+	if final.MessageKey != "" {
+		final.AddString(final.MessageKey, ent.Message)
+	}
+
+	// The context added through `With` is already encoded, the fields of the entry go in the last
+	// namespace it opened
+	if enc.buf.Len() > 0 {
+		final.addSeparator()
+		final.buf.Write(enc.buf.Bytes())
+	}
+	final.prefix = enc.prefix
+	addFields(final, fields)
+	final.prefix = ""
+
+	if ent.Stack != "" && final.StacktraceKey != "" {
+		final.AddString(final.StacktraceKey, ent.Stack)
+	}
+
+	if final.LineEnding != "" {
+		final.buf.AppendString(final.LineEnding)
+	} else {
+		final.buf.AppendString(zapcore.DefaultLineEnding)
+	}
+
+	return final.buf, nil
+}
+
+func (enc *logfmtEncoder) addSeparator() {
+	if enc.buf.Len() > 0 {
+		enc.buf.AppendByte(' ')
+	}
+}
+
+func (enc *logfmtEncoder) addKey(key string) {
+	enc.addSeparator()
+	appendLogfmtKey(enc.buf, enc.prefix+key)
+	enc.buf.AppendByte('=')
+}
+
+func (enc *logfmtEncoder) appendValue(val string) {
+	appendLogfmtValue(enc.buf, val)
+}
+
+// logfmtArrayEncoder writes the elements of an array separated by commas, it's rendered as a
+// single logfmt value once complete.
+type logfmtArrayEncoder struct {
+	config *zapcore.EncoderConfig
+	buf    *buffer.Buffer
+}
+
+func (enc *logfmtArrayEncoder) addElementSeparator() {
+	if last := enc.buf.Len() - 1; last >= 0 && enc.buf.Bytes()[last] != '[' {
+		enc.buf.AppendByte(',')
+	}
+}
+
+func (enc *logfmtArrayEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	enc.addElementSeparator()
+	enc.buf.AppendByte('[')
+	err := arr.MarshalLogArray(enc)
+	enc.buf.AppendByte(']')
+	return err
+}
+
+func (enc *logfmtArrayEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	value, err := logfmtObjectValue(enc.config, obj)
+	enc.addElementSeparator()
+	enc.buf.AppendString(value)
+	return err
+}
+
+func (enc *logfmtArrayEncoder) AppendReflected(val interface{}) error {
+	value, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	enc.addElementSeparator()
+	enc.buf.Write(value)
+	return nil
+}
+
+func (enc *logfmtArrayEncoder) AppendBool(val bool) {
+	enc.addElementSeparator()
+	enc.buf.AppendBool(val)
+}
+
+func (enc *logfmtArrayEncoder) AppendByteString(val []byte) {
+	enc.AppendString(string(val))
+}
+
+func (enc *logfmtArrayEncoder) AppendComplex128(val complex128) {
+	enc.addElementSeparator()
+	appendLogfmtComplex(enc.buf, val)
+}
+
+func (enc *logfmtArrayEncoder) AppendFloat64(val float64) {
+	enc.addElementSeparator()
+	appendLogfmtFloat(enc.buf, val, 64)
+}
+
+func (enc *logfmtArrayEncoder) AppendFloat32(val float32) {
+	enc.addElementSeparator()
+	appendLogfmtFloat(enc.buf, float64(val), 32)
+}
+
+func (enc *logfmtArrayEncoder) AppendInt64(val int64) {
+	enc.addElementSeparator()
+	enc.buf.AppendInt(val)
+}
+
+func (enc *logfmtArrayEncoder) AppendUint64(val uint64) {
+	enc.addElementSeparator()
+	enc.buf.AppendUint(val)
+}
+
+func (enc *logfmtArrayEncoder) AppendString(val string) {
+	enc.addElementSeparator()
+	appendLogfmtValue(enc.buf, val)
+}
+
+func (enc *logfmtArrayEncoder) AppendDuration(val time.Duration) {
+	enc.addElementSeparator()
+	cur := enc.buf.Len()
+	if enc.config.EncodeDuration != nil {
+		enc.config.EncodeDuration(val, &logfmtValueEncoder{enc.buf})
+	}
+	if cur == enc.buf.Len() {
+		appendLogfmtValue(enc.buf, val.String())
+	}
+}
+
+func (enc *logfmtArrayEncoder) AppendTime(val time.Time) {
+	enc.addElementSeparator()
+	cur := enc.buf.Len()
+	if enc.config.EncodeTime != nil {
+		enc.config.EncodeTime(val, &logfmtValueEncoder{enc.buf})
+	}
+	if cur == enc.buf.Len() {
+		appendLogfmtValue(enc.buf, val.Format(time.RFC3339Nano))
+	}
+}
+
+func (enc *logfmtArrayEncoder) AppendComplex64(v complex64) { enc.AppendComplex128(complex128(v)) }
+func (enc *logfmtArrayEncoder) AppendInt(v int)             { enc.AppendInt64(int64(v)) }
+func (enc *logfmtArrayEncoder) AppendInt32(v int32)         { enc.AppendInt64(int64(v)) }
+func (enc *logfmtArrayEncoder) AppendInt16(v int16)         { enc.AppendInt64(int64(v)) }
+func (enc *logfmtArrayEncoder) AppendInt8(v int8)           { enc.AppendInt64(int64(v)) }
+func (enc *logfmtArrayEncoder) AppendUint(v uint)           { enc.AppendUint64(uint64(v)) }
+func (enc *logfmtArrayEncoder) AppendUint32(v uint32)       { enc.AppendUint64(uint64(v)) }
+func (enc *logfmtArrayEncoder) AppendUint16(v uint16)       { enc.AppendUint64(uint64(v)) }
+func (enc *logfmtArrayEncoder) AppendUint8(v uint8)         { enc.AppendUint64(uint64(v)) }
+func (enc *logfmtArrayEncoder) AppendUintptr(v uintptr)     { enc.AppendUint64(uint64(v)) }
+
+// logfmtValueEncoder writes the primitive values produced by the `Encode...` functions of the
+// config within an array, without any separator.
+type logfmtValueEncoder struct {
+	buf *buffer.Buffer
+}
+
+func (enc *logfmtValueEncoder) AppendBool(v bool)             { enc.buf.AppendBool(v) }
+func (enc *logfmtValueEncoder) AppendByteString(v []byte)     { appendLogfmtValue(enc.buf, string(v)) }
+func (enc *logfmtValueEncoder) AppendComplex128(v complex128) { appendLogfmtComplex(enc.buf, v) }
+func (enc *logfmtValueEncoder) AppendComplex64(v complex64) {
+	appendLogfmtComplex(enc.buf, complex128(v))
+}
+func (enc *logfmtValueEncoder) AppendFloat64(v float64) { appendLogfmtFloat(enc.buf, v, 64) }
+func (enc *logfmtValueEncoder) AppendFloat32(v float32) { appendLogfmtFloat(enc.buf, float64(v), 32) }
+func (enc *logfmtValueEncoder) AppendInt(v int)         { enc.buf.AppendInt(int64(v)) }
+func (enc *logfmtValueEncoder) AppendInt64(v int64)     { enc.buf.AppendInt(v) }
+func (enc *logfmtValueEncoder) AppendInt32(v int32)     { enc.buf.AppendInt(int64(v)) }
+func (enc *logfmtValueEncoder) AppendInt16(v int16)     { enc.buf.AppendInt(int64(v)) }
+func (enc *logfmtValueEncoder) AppendInt8(v int8)       { enc.buf.AppendInt(int64(v)) }
+func (enc *logfmtValueEncoder) AppendString(v string)   { appendLogfmtValue(enc.buf, v) }
+func (enc *logfmtValueEncoder) AppendUint(v uint)       { enc.buf.AppendUint(uint64(v)) }
+func (enc *logfmtValueEncoder) AppendUint64(v uint64)   { enc.buf.AppendUint(v) }
+func (enc *logfmtValueEncoder) AppendUint32(v uint32)   { enc.buf.AppendUint(uint64(v)) }
+func (enc *logfmtValueEncoder) AppendUint16(v uint16)   { enc.buf.AppendUint(uint64(v)) }
+func (enc *logfmtValueEncoder) AppendUint8(v uint8)     { enc.buf.AppendUint(uint64(v)) }
+func (enc *logfmtValueEncoder) AppendUintptr(v uintptr) { enc.buf.AppendUint(uint64(v)) }
+
+// logfmtObjectValue renders an object found within an array as `{key=value key=value}`.
+func logfmtObjectValue(config *zapcore.EncoderConfig, obj zapcore.ObjectMarshaler) (string, error) {
+	object := &logfmtEncoder{EncoderConfig: config, buf: bufferpool.Get()}
+	defer object.buf.Free()
+
+	err := obj.MarshalLogObject(object)
+	return "{" + object.buf.String() + "}", err
+}
+
+// appendLogfmtKey writes the key replacing the characters that would break the `key=value`
+// parsing, i.e. spaces, `=`, quotes and control characters, by an underscore.
+func appendLogfmtKey(buf *buffer.Buffer, key string) {
+	if key == "" {
+		buf.AppendByte('_')
+		return
+	}
+
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			buf.AppendByte('_')
+			continue
+		}
+
+		buf.AppendString(string(r))
+	}
+}
+
+// appendLogfmtValue writes the value as-is when possible and quoted otherwise, escaping quotes,
+// backslashes and control characters like `strconv.Quote` would (but keeping printable unicode
+// characters).
+func appendLogfmtValue(buf *buffer.Buffer, val string) {
+	if !logfmtNeedsQuoting(val) {
+		buf.AppendString(val)
+		return
+	}
+
+	buf.AppendByte('"')
+	for i := 0; i < len(val); {
+		b := val[i]
+		if b < utf8.RuneSelf {
+			switch {
+			case b == '"' || b == '\\':
+				buf.AppendByte('\\')
+				buf.AppendByte(b)
+			case b == '\n':
+				buf.AppendString(`\n`)
+			case b == '\r':
+				buf.AppendString(`\r`)
+			case b == '\t':
+				buf.AppendString(`\t`)
+			case b < 0x20 || b == 0x7f:
+				buf.AppendString(`\u00`)
+				buf.AppendByte(_hex[b>>4])
+				buf.AppendByte(_hex[b&0xF])
+			default:
+				buf.AppendByte(b)
+			}
+
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(val[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.AppendString(`\ufffd`)
+		} else {
+			buf.AppendString(val[i : i+size])
+		}
+		i += size
+	}
+	buf.AppendByte('"')
+}
+
+func logfmtNeedsQuoting(val string) bool {
+	if val == "" || !utf8.ValidString(val) {
+		return true
+	}
+
+	for i := 0; i < len(val); i++ {
+		if b := val[i]; b <= ' ' || b == '=' || b == '"' || b == '\\' || b == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
+
+func appendLogfmtFloat(buf *buffer.Buffer, val float64, bitSize int) {
+	switch {
+	case math.IsNaN(val):
+		buf.AppendString("NaN")
+	case math.IsInf(val, 1):
+		buf.AppendString("+Inf")
+	case math.IsInf(val, -1):
+		buf.AppendString("-Inf")
+	default:
+		buf.AppendFloat(val, bitSize)
+	}
+}
+
+func appendLogfmtComplex(buf *buffer.Buffer, val complex128) {
+	r, i := float64(real(val)), float64(imag(val))
+	buf.AppendFloat(r, 64)
+	if i >= 0 {
+		buf.AppendByte('+')
+	}
+	buf.AppendFloat(i, 64)
+	buf.AppendByte('i')
+}
@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type logfmtTestUser struct {
+	name string
+	tags []string
+}
+
+func (u logfmtTestUser) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", u.name)
+	return enc.AddArray("tags", zap.Strings("", u.tags).Interface.(zapcore.ArrayMarshaler))
+}
+
+func TestLogfmtEncoder_Fields(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []zapcore.Field
+		expected string
+	}{
+		{"string", []zapcore.Field{zap.String("key", "value")}, "key=value"},
+		{"empty string", []zapcore.Field{zap.String("key", "")}, `key=""`},
+		{"string with spaces", []zapcore.Field{zap.String("key", "a value")}, `key="a value"`},
+		{"string with equal", []zapcore.Field{zap.String("key", "a=b")}, `key="a=b"`},
+		{"string escaped", []zapcore.Field{zap.String("key", "say \"hi\"\\\n\t\x01")}, `key="say \"hi\"\\\n\t\u0001"`},
+		{"string unicode", []zapcore.Field{zap.String("key", "héllo")}, "key=héllo"},
+		{"string invalid utf8", []zapcore.Field{zap.String("key", "a\xffb")}, `key="a\ufffdb"`},
+		{"key sanitized", []zapcore.Field{zap.String("a key=\"x\"", "v")}, "a_key__x_=v"},
+		{"numbers", []zapcore.Field{zap.Int("int", -3), zap.Uint8("uint", 3), zap.Float64("float", 1.5), zap.Float64("nan", math.NaN())}, "int=-3 uint=3 float=1.5 nan=NaN"},
+		{"complex", []zapcore.Field{zap.Complex128("c", complex(1, -2))}, "c=1-2i"},
+		{"bool", []zapcore.Field{zap.Bool("ok", true)}, "ok=true"},
+		{"duration", []zapcore.Field{zap.Duration("elapsed", 1500*time.Millisecond)}, "elapsed=1.5s"},
+		{"time", []zapcore.Field{zap.Time("at", time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))}, "at=2021-03-04T05:06:07Z"},
+		{"error", []zapcore.Field{zap.Error(errors.New("failed hard"))}, `error="failed hard"`},
+		{"binary", []zapcore.Field{zap.Binary("data", []byte{1, 2})}, `data="AQI="`},
+		{"array", []zapcore.Field{zap.Ints("ids", []int{1, 2, 3})}, "ids=[1,2,3]"},
+		{"array quoted", []zapcore.Field{zap.Strings("names", []string{"a", "b c"})}, `names="[a,\"b c\"]"`},
+		{"empty array", []zapcore.Field{zap.Strings("names", nil)}, "names=[]"},
+		{"object", []zapcore.Field{zap.Object("user", logfmtTestUser{"john", []string{"x", "y"}})}, "user.name=john user.tags=[x,y]"},
+		{"objects array", []zapcore.Field{zap.Array("users", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+			return enc.AppendObject(logfmtTestUser{"john", nil})
+		}))}, `users="[{name=john tags=[]}]"`},
+		{"namespace", []zapcore.Field{zap.String("a", "1"), zap.Namespace("ns"), zap.String("b", "2"), zap.Object("user", logfmtTestUser{name: "john"})}, "a=1 ns.b=2 ns.user.name=john ns.user.tags=[]"},
+		{"reflected", []zapcore.Field{zap.Reflect("map", map[string]int{"a": 1})}, `map="{\"a\":1}"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoder := NewLogfmtEncoder(zapcore.EncoderConfig{})
+
+			buffer, err := encoder.EncodeEntry(zapcore.Entry{}, test.fields)
+			require.NoError(t, err)
+			defer buffer.Free()
+
+			assert.Equal(t, test.expected+"\n", buffer.String())
+		})
+	}
+}
+
+func TestLogfmtEncoder_Entry(t *testing.T) {
+	encoder := NewLogfmtEncoder(newLogfmtEncoderConfig())
+	encoder.AddString("request", "r1")
+	encoder.OpenNamespace("ctx")
+
+	entry := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC),
+		LoggerName: "app",
+		Caller:     zapcore.EntryCaller{Defined: true, File: "github.com/acme/app/main.go", Line: 12},
+		Message:    "something went wrong",
+		Stack:      "main.main\n\tmain.go:12",
+	}
+
+	buffer, err := encoder.Clone().EncodeEntry(entry, []zapcore.Field{zap.Int("attempt", 2)})
+	require.NoError(t, err)
+	defer buffer.Free()
+
+	assert.Equal(t, `time=2021-03-04T05:06:07.000000008Z level=warn logger=app caller=app/main.go:12 msg="something went wrong" request=r1 ctx.attempt=2 stacktrace="main.main\n\tmain.go:12"`+"\n", buffer.String())
+}
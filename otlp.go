@@ -0,0 +1,641 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type otlpOptions struct {
+	json                bool
+	headers             map[string]string
+	resource            map[string]string
+	batchSize           int
+	queueSize           int
+	flushInterval       time.Duration
+	timeout             time.Duration
+	maxRetries          int
+	retryInitialBackoff time.Duration
+	retryMaxBackoff     time.Duration
+	withoutFallback     bool
+}
+
+// OTLPOption configures the OTLP output of a sink, see `SinkToOTLP`.
+type OTLPOption interface {
+	apply(o *otlpOptions)
+}
+
+type otlpOptionFunc func(o *otlpOptions)
+
+func (f otlpOptionFunc) apply(o *otlpOptions) {
+	f(o)
+}
+
+// OTLPJSON exports the entries in the OTLP/JSON encoding instead of protobuf.
+func OTLPJSON() OTLPOption {
+	return otlpOptionFunc(func(o *otlpOptions) {
+		o.json = true
+	})
+}
+
+// OTLPHeaders are added to the export requests, typically to authenticate with the collector.
+func OTLPHeaders(headers map[string]string) OTLPOption {
+	return otlpOptionFunc(func(o *otlpOptions) {
+		o.headers = headers
+	})
+}
+
+// OTLPResource are the attributes of the resource the entries are exported for. The
+// `service.name` attribute is the short name of the root logger by default, or the process name
+// if there is no root logger.
+func OTLPResource(attributes map[string]string) OTLPOption {
+	return otlpOptionFunc(func(o *otlpOptions) {
+		o.resource = attributes
+	})
+}
+
+// OTLPBatchSize is the maximum number of entries exported per request, 512 by default. The
+// function panics if the size is not positive.
+func OTLPBatchSize(size int) OTLPOption {
+	if size <= 0 {
+		panic(fmt.Errorf("invalid OTLP batch size %d, it must be greater than 0", size))
+	}
+
+	return otlpOptionFunc(func(o *otlpOptions) {
+		o.batchSize = size
+	})
+}
+
+// OTLPQueueSize is the maximum number of entries waiting to be exported, 2048 by default. The
+// function panics if the size is not positive.
+func OTLPQueueSize(size int) OTLPOption {
+	if size <= 0 {
+		panic(fmt.Errorf("invalid OTLP queue size %d, it must be greater than 0", size))
+	}
+
+	return otlpOptionFunc(func(o *otlpOptions) {
+		o.queueSize = size
+	})
+}
+
+// OTLPFlushInterval is the maximum time an entry waits before being exported, 1 second by
+// default. A batch is exported right away once full.
+func OTLPFlushInterval(interval time.Duration) OTLPOption {
+	if interval <= 0 {
+		panic(fmt.Errorf("invalid OTLP flush interval %s, it must be greater than 0", interval))
+	}
+
+	return otlpOptionFunc(func(o *otlpOptions) {
+		o.flushInterval = interval
+	})
+}
+
+// OTLPTimeout is the timeout of each export request, 10 seconds by default.
+func OTLPTimeout(timeout time.Duration) OTLPOption {
+	return otlpOptionFunc(func(o *otlpOptions) {
+		o.timeout = timeout
+	})
+}
+
+// OTLPRetry configures how a batch is retried when the collector is unreachable or answers
+// with a retryable status (429, 502, 503 or 504): at most `maxRetries` times, waiting
+// `initialBackoff` before the first retry and doubling the wait each time up to `maxBackoff`. By
+// default, a batch is retried 3 times starting at 500ms up to 5 seconds.
+func OTLPRetry(maxRetries int, initialBackoff time.Duration, maxBackoff time.Duration) OTLPOption {
+	if maxRetries < 0 {
+		panic(fmt.Errorf("invalid OTLP max retries %d, it must be positive", maxRetries))
+	}
+
+	return otlpOptionFunc(func(o *otlpOptions) {
+		o.maxRetries = maxRetries
+		o.retryInitialBackoff = initialBackoff
+		o.retryMaxBackoff = maxBackoff
+	})
+}
+
+// OTLPWithoutFallback drops the entries that could not be exported instead of writing them to
+// the console, `Instance.DroppedLogEntries` reporting how many were dropped.
+func OTLPWithoutFallback() OTLPOption {
+	return otlpOptionFunc(func(o *otlpOptions) {
+		o.withoutFallback = true
+	})
+}
+
+// SinkToOTLP exports the sink's entries to an OpenTelemetry collector over OTLP/HTTP, the
+// `endpoint` being the full URL receiving the requests, like `http://localhost:4318/v1/logs`.
+// Entries are encoded in protobuf unless `OTLPJSON` is given, the logger name becoming the
+// instrumentation scope, the caller the `code.*` attributes, the `error` field the
+// `exception.*` attributes and the `trace_id` and `span_id` fields the trace context of the
+// record.
+//
+// Entries are queued and exported in batches by a background goroutine, see `OTLPBatchSize`,
+// `OTLPQueueSize` and `OTLPFlushInterval`, failed exports being retried with an exponential
+// backoff, see `OTLPRetry`. When a batch can't be exported, or when the queue is full, its
+// entries are written to the console instead unless it already wrote them because of its level,
+// see `OTLPWithoutFallback`. The queue is exported when a logger is synced and when a DPanic,
+// Panic or Fatal entry is logged, as well as when the `Instance` is closed, following entries
+// being written to the console.
+//
+// The sink writes its own format, `SinkFormat` is ignored.
+func SinkToOTLP(endpoint string, options ...OTLPOption) SinkOption {
+	if endpoint == "" {
+		panic(fmt.Errorf("the OTLP endpoint is empty, this is not accepted as a valid option"))
+	}
+
+	// The exporter is shared by all the loggers instantiated with the option
+	exporter := newOTLPExporter(endpoint, options...)
+
+	return sinkOptionFunc(func(s *sink) {
+		s.output = sinkOutput{otlp: exporter}
+	})
+}
+
+// otlpCore is a `zapcore.Core` queuing each entry into the `exporter`.
+type otlpCore struct {
+	zapcore.LevelEnabler
+
+	exporter *otlpExporter
+
+	// console is the level of the logger's console, the entries it enables are not written again
+	// by the fallback
+	console zapcore.LevelEnabler
+	fields  []zapcore.Field
+}
+
+func newOTLPCore(exporter *otlpExporter, level zapcore.LevelEnabler, console zapcore.LevelEnabler) *otlpCore {
+	return &otlpCore{LevelEnabler: level, exporter: exporter, console: console}
+}
+
+func (c *otlpCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append([]zapcore.Field(nil), c.fields...), fields...)
+
+	return &clone
+}
+
+func (c *otlpCore) Check(ent zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return checked.AddCore(ent, c)
+	}
+
+	return checked
+}
+
+func (c *otlpCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.exporter.enqueue(newOTLPEntry(ent, append(append([]zapcore.Field(nil), c.fields...), fields...), c.console.Enabled(ent.Level)))
+
+	// Like zap's own cores, the entries that may end the process are exported right away, but with
+	// a single short attempt so that a slow collector doesn't delay the exit
+	if ent.Level > zapcore.ErrorLevel {
+		c.exporter.flushUrgently()
+	}
+
+	return nil
+}
+
+// Sync exports the queued entries, those that can't be exported are written to the fallback.
+func (c *otlpCore) Sync() error {
+	c.exporter.flush()
+	return nil
+}
+
+// otlpEntry is an entry waiting to be exported, its fields are converted right away as they may
+// reference values changing once the logging call returns.
+type otlpEntry struct {
+	entry     zapcore.Entry
+	fields    []otlpKeyValue
+	exception *otlpException
+
+	// onConsole is true when the console already wrote the entry
+	onConsole bool
+}
+
+type otlpException struct {
+	message   string
+	errorType string
+}
+
+func newOTLPEntry(ent zapcore.Entry, fields []zapcore.Field, onConsole bool) *otlpEntry {
+	out := &otlpEntry{entry: ent, onConsole: onConsole}
+
+	for _, field := range fields {
+		if isErrorField(field) {
+			err := field.Interface.(error)
+			out.exception = &otlpException{message: err.Error(), errorType: fmt.Sprintf("%T", err)}
+			continue
+		}
+
+		encoder := zapcore.NewMapObjectEncoder()
+		field.AddTo(encoder)
+
+		keys := make([]string, 0, len(encoder.Fields))
+		for key := range encoder.Fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			out.fields = append(out.fields, otlpKeyValue{key, otlpValueOf(encoder.Fields[key])})
+		}
+	}
+
+	return out
+}
+
+func (e *otlpEntry) record() otlpLogRecord {
+	severityNumber, severityText := otelSeverity(e.entry.Level)
+	record := otlpLogRecord{
+		timeUnixNano:   uint64(e.entry.Time.UnixNano()),
+		severityNumber: severityNumber,
+		severityText:   severityText,
+		body:           e.entry.Message,
+	}
+
+	if e.entry.Caller.Defined {
+		record.attributes = append(record.attributes,
+			otlpKeyValue{"code.filepath", otlpString(e.entry.Caller.File)},
+			otlpKeyValue{"code.lineno", otlpInt(int64(e.entry.Caller.Line))},
+		)
+		if e.entry.Caller.Function != "" {
+			record.attributes = append(record.attributes, otlpKeyValue{"code.function", otlpString(e.entry.Caller.Function)})
+		}
+	}
+
+	for _, field := range e.fields {
+		switch {
+		case field.key == traceIDKey && record.traceID == nil:
+			if record.traceID = otlpTraceContextID(field.value, 16); record.traceID != nil {
+				continue
+			}
+		case field.key == spanIDKey && record.spanID == nil:
+			if record.spanID = otlpTraceContextID(field.value, 8); record.spanID != nil {
+				continue
+			}
+		}
+
+		record.attributes = append(record.attributes, field)
+	}
+
+	if e.exception != nil {
+		record.attributes = append(record.attributes,
+			otlpKeyValue{"exception.message", otlpString(e.exception.message)},
+			otlpKeyValue{"exception.type", otlpString(e.exception.errorType)},
+		)
+	}
+
+	if e.entry.Stack != "" {
+		record.attributes = append(record.attributes, otlpKeyValue{"exception.stacktrace", otlpString(e.entry.Stack)})
+	}
+
+	return record
+}
+
+// zapFields returns the fields as written by the fallback.
+func (e *otlpEntry) zapFields() []zapcore.Field {
+	fields := make([]zapcore.Field, 0, len(e.fields)+1)
+	for _, field := range e.fields {
+		fields = append(fields, zap.Any(field.key, field.value.native()))
+	}
+
+	if e.exception != nil {
+		fields = append(fields, zap.String(errorKey, e.exception.message))
+	}
+
+	return fields
+}
+
+// otlpExporter queues the entries of all the loggers of a sink, a background goroutine started
+// with the first entry exporting them in batches.
+// otlpUrgentExportTimeout bounds the export of the entries that may end the process.
+const otlpUrgentExportTimeout = time.Second
+
+type otlpExporter struct {
+	endpoint string
+	options  otlpOptions
+	client   *http.Client
+
+	lock     sync.Mutex
+	queue    []*otlpEntry
+	started  bool
+	stopped  bool
+	fallback zapcore.Core
+	resource []otlpKeyValue
+
+	// exportLock orders the batches exported by the background goroutine and `flush`
+	exportLock sync.Mutex
+
+	dropped *atomic.Uint64
+	wakeup  chan struct{}
+	done    chan struct{}
+	exited  chan struct{}
+}
+
+func newOTLPExporter(endpoint string, options ...OTLPOption) *otlpExporter {
+	exporter := &otlpExporter{
+		endpoint: endpoint,
+		options: otlpOptions{
+			batchSize:           512,
+			queueSize:           2048,
+			flushInterval:       time.Second,
+			timeout:             10 * time.Second,
+			maxRetries:          3,
+			retryInitialBackoff: 500 * time.Millisecond,
+			retryMaxBackoff:     5 * time.Second,
+		},
+		dropped: atomic.NewUint64(0),
+		wakeup:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+
+	for _, opt := range options {
+		opt.apply(&exporter.options)
+	}
+
+	exporter.client = &http.Client{Timeout: exporter.options.timeout}
+	exporter.resource = exporter.resourceAttributes("")
+
+	return exporter
+}
+
+// configure sets the core entries are written to when they can't be exported, and the service
+// name used when none is given by `OTLPResource`.
+func (e *otlpExporter) configure(fallback zapcore.Core, serviceName string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if !e.options.withoutFallback {
+		e.fallback = fallback
+	}
+	e.resource = e.resourceAttributes(serviceName)
+}
+
+func (e *otlpExporter) resourceAttributes(serviceName string) []otlpKeyValue {
+	if serviceName == "" {
+		serviceName = filepath.Base(os.Args[0])
+	}
+
+	attributes := map[string]string{"service.name": serviceName}
+	for key, value := range e.options.resource {
+		attributes[key] = value
+	}
+
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := make([]otlpKeyValue, len(keys))
+	for i, key := range keys {
+		out[i] = otlpKeyValue{key, otlpString(attributes[key])}
+	}
+
+	return out
+}
+
+func (e *otlpExporter) enqueue(entry *otlpEntry) {
+	e.lock.Lock()
+
+	if e.stopped || len(e.queue) >= e.options.queueSize {
+		e.lock.Unlock()
+		e.fallbackWrite([]*otlpEntry{entry})
+		return
+	}
+
+	if !e.started {
+		e.started = true
+		go e.run()
+	}
+
+	e.queue = append(e.queue, entry)
+	full := len(e.queue) >= e.options.batchSize
+	e.lock.Unlock()
+
+	if full {
+		select {
+		case e.wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *otlpExporter) run() {
+	defer close(e.exited)
+
+	ticker := time.NewTicker(e.options.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		case <-e.wakeup:
+		}
+
+		e.flush()
+	}
+}
+
+// flush exports the queued entries in batches, writing those of the batches that can't be
+// exported to the fallback.
+func (e *otlpExporter) flush() {
+	e.exportLock.Lock()
+	defer e.exportLock.Unlock()
+
+	for {
+		e.lock.Lock()
+		batch := e.queue
+		if len(batch) > e.options.batchSize {
+			batch = batch[:e.options.batchSize]
+		}
+		e.queue = e.queue[len(batch):]
+		e.lock.Unlock()
+
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := e.export(context.Background(), batch, e.options.maxRetries); err != nil {
+			e.reportFailure(len(batch), err)
+			e.fallbackWrite(batch)
+		}
+	}
+}
+
+// flushUrgently exports the queued entries without retrying and within `otlpUrgentExportTimeout`,
+// writing those that can't be exported to the fallback. It doesn't wait for an export in progress,
+// it's used for the entries that may end the process.
+func (e *otlpExporter) flushUrgently() {
+	e.lock.Lock()
+	queue := e.queue
+	e.queue = nil
+	e.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), otlpUrgentExportTimeout)
+	defer cancel()
+
+	for len(queue) > 0 {
+		batch := queue
+		if len(batch) > e.options.batchSize {
+			batch = batch[:e.options.batchSize]
+		}
+		queue = queue[len(batch):]
+
+		if err := e.export(ctx, batch, 0); err != nil {
+			e.reportFailure(len(batch), err)
+			e.fallbackWrite(batch)
+		}
+	}
+}
+
+// stop exports the queued entries and stops the background goroutine, following entries being
+// written to the fallback.
+func (e *otlpExporter) stop() {
+	e.lock.Lock()
+	if e.stopped {
+		e.lock.Unlock()
+		return
+	}
+
+	e.stopped = true
+	started := e.started
+	e.lock.Unlock()
+
+	if started {
+		close(e.done)
+		<-e.exited
+	}
+
+	e.flush()
+}
+
+// export sends the batch, retrying up to `maxRetries` times when the failure is transient.
+func (e *otlpExporter) export(ctx context.Context, batch []*otlpEntry, maxRetries int) error {
+	e.lock.Lock()
+	resource := e.resource
+	e.lock.Unlock()
+
+	var scopes []otlpScopeLogs
+	scopeIndexes := map[string]int{}
+	for _, entry := range batch {
+		index, found := scopeIndexes[entry.entry.LoggerName]
+		if !found {
+			index = len(scopes)
+			scopeIndexes[entry.entry.LoggerName] = index
+			scopes = append(scopes, otlpScopeLogs{name: entry.entry.LoggerName})
+		}
+
+		scopes[index].records = append(scopes[index].records, entry.record())
+	}
+
+	contentType := "application/x-protobuf"
+	var body []byte
+	if e.options.json {
+		var err error
+		if body, err = encodeOTLPJSON(resource, scopes); err != nil {
+			return fmt.Errorf("encode: %w", err)
+		}
+		contentType = "application/json"
+	} else {
+		body = encodeOTLPProtobuf(resource, scopes)
+	}
+
+	backoff := e.options.retryInitialBackoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := e.post(ctx, body, contentType)
+		if err == nil {
+			return nil
+		}
+
+		if !retryable || attempt >= maxRetries {
+			return err
+		}
+
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > e.options.retryMaxBackoff {
+			backoff = e.options.retryMaxBackoff
+		}
+	}
+}
+
+// post sends the request, returning whether it may succeed if retried when it fails.
+func (e *otlpExporter) post(ctx context.Context, body []byte, contentType string) (retryable bool, err error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("create request: %w", err)
+	}
+
+	request.Header.Set("Content-Type", contentType)
+	for key, value := range e.options.headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("collector answered %s: %s", response.Status, bytes.TrimSpace(responseBody))
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, err
+	}
+
+	return false, err
+}
+
+func (e *otlpExporter) currentFallback() zapcore.Core {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.fallback
+}
+
+// fallbackWrite writes the entries the console didn't already write to the fallback, or drops
+// them if there is no fallback.
+func (e *otlpExporter) fallbackWrite(entries []*otlpEntry) {
+	fallback := e.currentFallback()
+	if fallback == nil {
+		e.dropped.Add(uint64(len(entries)))
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.onConsole {
+			fallback.Write(entry.entry, entry.zapFields())
+		}
+	}
+}
+
+func (e *otlpExporter) reportFailure(count int, err error) {
+	fallback := e.currentFallback()
+	if fallback == nil {
+		return
+	}
+
+	fallback.Write(zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Now(),
+		LoggerName: "logging",
+		Message:    "unable to export log entries to the OTLP collector, writing them to the console",
+	}, []zapcore.Field{zap.String("endpoint", e.endpoint), zap.Int("count", count), zap.Error(err)})
+}
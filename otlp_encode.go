@@ -0,0 +1,389 @@
+package logging
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type otlpValueKind uint8
+
+const (
+	otlpEmptyValue otlpValueKind = iota
+	otlpStringValue
+	otlpBoolValue
+	otlpIntValue
+	otlpDoubleValue
+	otlpArrayValue
+	otlpKeyValueListValue
+	otlpBytesValue
+)
+
+// otlpValue is an OTLP `AnyValue`.
+type otlpValue struct {
+	kind    otlpValueKind
+	str     string
+	boolean bool
+	integer int64
+	double  float64
+	bytes   []byte
+	array   []otlpValue
+	kvList  []otlpKeyValue
+}
+
+type otlpKeyValue struct {
+	key   string
+	value otlpValue
+}
+
+func otlpString(value string) otlpValue {
+	return otlpValue{kind: otlpStringValue, str: value}
+}
+
+func otlpInt(value int64) otlpValue {
+	return otlpValue{kind: otlpIntValue, integer: value}
+}
+
+// otlpValueOf converts a value produced by `zapcore.MapObjectEncoder`, values of other types
+// being written in JSON as a string.
+func otlpValueOf(value interface{}) otlpValue {
+	switch v := value.(type) {
+	case nil:
+		return otlpValue{}
+	case string:
+		return otlpString(v)
+	case bool:
+		return otlpValue{kind: otlpBoolValue, boolean: v}
+	case int:
+		return otlpInt(int64(v))
+	case int8:
+		return otlpInt(int64(v))
+	case int16:
+		return otlpInt(int64(v))
+	case int32:
+		return otlpInt(int64(v))
+	case int64:
+		return otlpInt(v)
+	case uint:
+		return otlpInt(int64(v))
+	case uint8:
+		return otlpInt(int64(v))
+	case uint16:
+		return otlpInt(int64(v))
+	case uint32:
+		return otlpInt(int64(v))
+	case uint64:
+		if v > math.MaxInt64 {
+			return otlpString(strconv.FormatUint(v, 10))
+		}
+		return otlpInt(int64(v))
+	case uintptr:
+		return otlpInt(int64(v))
+	case float32:
+		return otlpValue{kind: otlpDoubleValue, double: float64(v)}
+	case float64:
+		return otlpValue{kind: otlpDoubleValue, double: v}
+	case complex64, complex128:
+		return otlpString(fmt.Sprint(v))
+	case []byte:
+		return otlpValue{kind: otlpBytesValue, bytes: append([]byte(nil), v...)}
+	case time.Duration:
+		return otlpString(v.String())
+	case time.Time:
+		return otlpString(v.Format(time.RFC3339Nano))
+	case []interface{}:
+		array := make([]otlpValue, len(v))
+		for i, element := range v {
+			array[i] = otlpValueOf(element)
+		}
+		return otlpValue{kind: otlpArrayValue, array: array}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		kvList := make([]otlpKeyValue, len(keys))
+		for i, key := range keys {
+			kvList[i] = otlpKeyValue{key, otlpValueOf(v[key])}
+		}
+		return otlpValue{kind: otlpKeyValueListValue, kvList: kvList}
+	}
+
+	return otlpString(stringFieldValue(value))
+}
+
+// native returns the value as the Go value `zap.Any` renders the closest to the original field.
+func (v otlpValue) native() interface{} {
+	switch v.kind {
+	case otlpStringValue:
+		return v.str
+	case otlpBoolValue:
+		return v.boolean
+	case otlpIntValue:
+		return v.integer
+	case otlpDoubleValue:
+		return v.double
+	case otlpBytesValue:
+		return v.bytes
+	case otlpArrayValue:
+		out := make([]interface{}, len(v.array))
+		for i, element := range v.array {
+			out[i] = element.native()
+		}
+		return out
+	case otlpKeyValueListValue:
+		out := make(map[string]interface{}, len(v.kvList))
+		for _, kv := range v.kvList {
+			out[kv.key] = kv.value.native()
+		}
+		return out
+	}
+
+	return nil
+}
+
+// otlpLogRecord is an OTLP `LogRecord`.
+type otlpLogRecord struct {
+	timeUnixNano   uint64
+	severityNumber int
+	severityText   string
+	body           string
+	attributes     []otlpKeyValue
+	traceID        []byte
+	spanID         []byte
+}
+
+// otlpScopeLogs is an OTLP `ScopeLogs`, the scope being named after the logger.
+type otlpScopeLogs struct {
+	name    string
+	records []otlpLogRecord
+}
+
+// otlpTraceContextID decodes the hex trace or span ID of `size` bytes, returning `nil` if invalid.
+func otlpTraceContextID(value otlpValue, size int) []byte {
+	if value.kind != otlpStringValue || len(value.str) != 2*size {
+		return nil
+	}
+
+	id, err := hex.DecodeString(value.str)
+	if err != nil {
+		return nil
+	}
+
+	return id
+}
+
+// The protobuf wire types, the field numbers of the messages below being those of
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/logs/v1/logs.proto
+// and its `common.proto` and `resource.proto` imports.
+const (
+	protoWireVarint  = 0
+	protoWireFixed64 = 1
+	protoWireBytes   = 2
+)
+
+// protoBuffer appends protobuf encoded fields.
+type protoBuffer []byte
+
+func (b *protoBuffer) appendTag(field int, wireType int) {
+	b.appendVarint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protoBuffer) appendVarint(value uint64) {
+	*b = append(*b, make([]byte, binary.MaxVarintLen64)...)
+	n := binary.PutUvarint((*b)[len(*b)-binary.MaxVarintLen64:], value)
+	*b = (*b)[:len(*b)-binary.MaxVarintLen64+n]
+}
+
+func (b *protoBuffer) appendBytes(field int, value []byte) {
+	b.appendTag(field, protoWireBytes)
+	b.appendVarint(uint64(len(value)))
+	*b = append(*b, value...)
+}
+
+func (b *protoBuffer) appendString(field int, value string) {
+	b.appendBytes(field, []byte(value))
+}
+
+func (b *protoBuffer) appendFixed64(field int, value uint64) {
+	b.appendTag(field, protoWireFixed64)
+	*b = append(*b, make([]byte, 8)...)
+	binary.LittleEndian.PutUint64((*b)[len(*b)-8:], value)
+}
+
+func (b *protoBuffer) appendMessage(field int, encode func(message *protoBuffer)) {
+	var message protoBuffer
+	encode(&message)
+	b.appendBytes(field, message)
+}
+
+func (b *protoBuffer) appendAnyValue(field int, value otlpValue) {
+	b.appendMessage(field, func(message *protoBuffer) {
+		switch value.kind {
+		case otlpStringValue:
+			message.appendString(1, value.str)
+		case otlpBoolValue:
+			message.appendTag(2, protoWireVarint)
+			if value.boolean {
+				message.appendVarint(1)
+			} else {
+				message.appendVarint(0)
+			}
+		case otlpIntValue:
+			message.appendTag(3, protoWireVarint)
+			message.appendVarint(uint64(value.integer))
+		case otlpDoubleValue:
+			message.appendFixed64(4, math.Float64bits(value.double))
+		case otlpArrayValue:
+			message.appendMessage(5, func(array *protoBuffer) {
+				for _, element := range value.array {
+					array.appendAnyValue(1, element)
+				}
+			})
+		case otlpKeyValueListValue:
+			message.appendMessage(6, func(kvList *protoBuffer) {
+				kvList.appendKeyValues(1, value.kvList)
+			})
+		case otlpBytesValue:
+			message.appendBytes(7, value.bytes)
+		}
+	})
+}
+
+func (b *protoBuffer) appendKeyValues(field int, kvs []otlpKeyValue) {
+	for _, kv := range kvs {
+		b.appendMessage(field, func(message *protoBuffer) {
+			message.appendString(1, kv.key)
+			message.appendAnyValue(2, kv.value)
+		})
+	}
+}
+
+// encodeOTLPProtobuf encodes an `ExportLogsServiceRequest` with a single resource.
+func encodeOTLPProtobuf(resource []otlpKeyValue, scopes []otlpScopeLogs) []byte {
+	var request protoBuffer
+	request.appendMessage(1, func(resourceLogs *protoBuffer) {
+		resourceLogs.appendMessage(1, func(message *protoBuffer) {
+			message.appendKeyValues(1, resource)
+		})
+
+		for _, scope := range scopes {
+			resourceLogs.appendMessage(2, func(scopeLogs *protoBuffer) {
+				scopeLogs.appendMessage(1, func(message *protoBuffer) {
+					if scope.name != "" {
+						message.appendString(1, scope.name)
+					}
+				})
+
+				for _, record := range scope.records {
+					scopeLogs.appendMessage(2, func(message *protoBuffer) {
+						message.appendFixed64(1, record.timeUnixNano)
+						message.appendTag(2, protoWireVarint)
+						message.appendVarint(uint64(record.severityNumber))
+						message.appendString(3, record.severityText)
+						message.appendAnyValue(5, otlpString(record.body))
+						message.appendKeyValues(6, record.attributes)
+						if record.traceID != nil {
+							message.appendBytes(9, record.traceID)
+						}
+						if record.spanID != nil {
+							message.appendBytes(10, record.spanID)
+						}
+						message.appendFixed64(11, record.timeUnixNano)
+					})
+				}
+			})
+		}
+	})
+
+	return request
+}
+
+// encodeOTLPJSON encodes an `ExportLogsServiceRequest` with a single resource following the
+// OTLP/JSON conventions, the 64 bits integers being strings and the trace and span IDs hex
+// strings.
+func encodeOTLPJSON(resource []otlpKeyValue, scopes []otlpScopeLogs) ([]byte, error) {
+	jsonScopes := make([]interface{}, len(scopes))
+	for i, scope := range scopes {
+		records := make([]interface{}, len(scope.records))
+		for j, record := range scope.records {
+			jsonRecord := map[string]interface{}{
+				"timeUnixNano":         strconv.FormatUint(record.timeUnixNano, 10),
+				"observedTimeUnixNano": strconv.FormatUint(record.timeUnixNano, 10),
+				"severityNumber":       record.severityNumber,
+				"severityText":         record.severityText,
+				"body":                 otlpString(record.body).jsonValue(),
+				"attributes":           otlpJSONKeyValues(record.attributes),
+			}
+
+			if record.traceID != nil {
+				jsonRecord["traceId"] = hex.EncodeToString(record.traceID)
+			}
+			if record.spanID != nil {
+				jsonRecord["spanId"] = hex.EncodeToString(record.spanID)
+			}
+
+			records[j] = jsonRecord
+		}
+
+		jsonScope := map[string]interface{}{}
+		if scope.name != "" {
+			jsonScope["name"] = scope.name
+		}
+
+		jsonScopes[i] = map[string]interface{}{"scope": jsonScope, "logRecords": records}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"resourceLogs": []interface{}{
+			map[string]interface{}{
+				"resource":  map[string]interface{}{"attributes": otlpJSONKeyValues(resource)},
+				"scopeLogs": jsonScopes,
+			},
+		},
+	})
+}
+
+func otlpJSONKeyValues(kvs []otlpKeyValue) []interface{} {
+	out := make([]interface{}, len(kvs))
+	for i, kv := range kvs {
+		out[i] = map[string]interface{}{"key": kv.key, "value": kv.value.jsonValue()}
+	}
+
+	return out
+}
+
+func (v otlpValue) jsonValue() map[string]interface{} {
+	switch v.kind {
+	case otlpStringValue:
+		return map[string]interface{}{"stringValue": v.str}
+	case otlpBoolValue:
+		return map[string]interface{}{"boolValue": v.boolean}
+	case otlpIntValue:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v.integer, 10)}
+	case otlpDoubleValue:
+		if math.IsNaN(v.double) || math.IsInf(v.double, 0) {
+			return map[string]interface{}{"stringValue": strconv.FormatFloat(v.double, 'g', -1, 64)}
+		}
+		return map[string]interface{}{"doubleValue": v.double}
+	case otlpArrayValue:
+		values := make([]interface{}, len(v.array))
+		for i, element := range v.array {
+			values[i] = element.jsonValue()
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case otlpKeyValueListValue:
+		return map[string]interface{}{"kvlistValue": map[string]interface{}{"values": otlpJSONKeyValues(v.kvList)}}
+	case otlpBytesValue:
+		return map[string]interface{}{"bytesValue": v.bytes}
+	}
+
+	return map[string]interface{}{}
+}
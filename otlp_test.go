@@ -0,0 +1,380 @@
+package logging
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestOTLPExporter_JSON(t *testing.T) {
+	collector := newFakeCollector(t)
+	exporter := newOTLPExporter(collector.server.URL, OTLPJSON(), OTLPResource(map[string]string{"deployment.environment": "test"}), OTLPHeaders(map[string]string{"Authorization": "Bearer secret"}))
+	exporter.configure(nil, "app")
+
+	logger := zap.New(newOTLPCore(exporter, zapcore.DebugLevel, zapcore.FatalLevel)).Named("p2p")
+	logger.With(zap.String("peer_id", "abc")).Error("peer failed",
+		zap.Error(errors.New("connection reset")),
+		zap.String("trace_id", "0af7651916cd43dd8448eb211c80319c"),
+		zap.String("span_id", "b7ad6b7169203331"),
+		zap.Int("attempt", 2),
+		zap.Bool("retry", true),
+	)
+	logger.Sync()
+
+	requests := collector.received()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "application/json", requests[0].contentType)
+	assert.Equal(t, "Bearer secret", requests[0].authorization)
+
+	var request struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []map[string]interface{} `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				Scope      map[string]interface{}   `json:"scope"`
+				LogRecords []map[string]interface{} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	require.NoError(t, json.Unmarshal(requests[0].body, &request))
+	require.Len(t, request.ResourceLogs, 1)
+
+	resourceLogs := request.ResourceLogs[0]
+	assert.Equal(t, []map[string]interface{}{
+		{"key": "deployment.environment", "value": map[string]interface{}{"stringValue": "test"}},
+		{"key": "service.name", "value": map[string]interface{}{"stringValue": "app"}},
+	}, resourceLogs.Resource.Attributes)
+
+	require.Len(t, resourceLogs.ScopeLogs, 1)
+	assert.Equal(t, map[string]interface{}{"name": "p2p"}, resourceLogs.ScopeLogs[0].Scope)
+	require.Len(t, resourceLogs.ScopeLogs[0].LogRecords, 1)
+
+	record := resourceLogs.ScopeLogs[0].LogRecords[0]
+	assert.Equal(t, float64(17), record["severityNumber"])
+	assert.Equal(t, "ERROR", record["severityText"])
+	assert.Equal(t, map[string]interface{}{"stringValue": "peer failed"}, record["body"])
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", record["traceId"])
+	assert.Equal(t, "b7ad6b7169203331", record["spanId"])
+	assert.NotEmpty(t, record["timeUnixNano"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"key": "peer_id", "value": map[string]interface{}{"stringValue": "abc"}},
+		map[string]interface{}{"key": "attempt", "value": map[string]interface{}{"intValue": "2"}},
+		map[string]interface{}{"key": "retry", "value": map[string]interface{}{"boolValue": true}},
+		map[string]interface{}{"key": "exception.message", "value": map[string]interface{}{"stringValue": "connection reset"}},
+		map[string]interface{}{"key": "exception.type", "value": map[string]interface{}{"stringValue": "*errors.errorString"}},
+	}, record["attributes"])
+}
+
+func TestOTLPExporter_Protobuf(t *testing.T) {
+	collector := newFakeCollector(t)
+	exporter := newOTLPExporter(collector.server.URL)
+	exporter.configure(nil, "app")
+
+	now := time.Now()
+	exporter.enqueue(newOTLPEntry(zapcore.Entry{Level: zapcore.WarnLevel, Time: now, LoggerName: "p2p", Message: "slow peer"}, []zapcore.Field{
+		zap.String("trace_id", "0af7651916cd43dd8448eb211c80319c"),
+		zap.Int64("latency_ms", 350),
+	}, false))
+	exporter.flush()
+
+	requests := collector.received()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "application/x-protobuf", requests[0].contentType)
+
+	resourceLogs := protoMessages(t, requests[0].body, 1)
+	require.Len(t, resourceLogs, 1)
+
+	resource := protoMessages(t, resourceLogs[0], 1)[0]
+	assert.Equal(t, [][2]string{{"service.name", "app"}}, protoStringAttributes(t, resource, 1))
+
+	scopeLogs := protoMessages(t, resourceLogs[0], 2)
+	require.Len(t, scopeLogs, 1)
+	assert.Equal(t, "p2p", string(protoMessages(t, protoMessages(t, scopeLogs[0], 1)[0], 1)[0]))
+
+	records := protoMessages(t, scopeLogs[0], 2)
+	require.Len(t, records, 1)
+
+	fields := parseProto(t, records[0])
+	assert.Equal(t, uint64(now.UnixNano()), fields[1][0].number)
+	assert.Equal(t, uint64(13), fields[2][0].number)
+	assert.Equal(t, "WARN", string(fields[3][0].data))
+	assert.Equal(t, "slow peer", string(protoMessages(t, fields[5][0].data, 1)[0]))
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", hex.EncodeToString(fields[9][0].data))
+	require.Len(t, fields[6], 1)
+
+	attribute := parseProto(t, fields[6][0].data)
+	assert.Equal(t, "latency_ms", string(attribute[1][0].data))
+	assert.Equal(t, uint64(350), parseProto(t, attribute[2][0].data)[3][0].number)
+}
+
+func TestOTLPExporter_Retry(t *testing.T) {
+	collector := newFakeCollector(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	exporter := newOTLPExporter(collector.server.URL, OTLPRetry(3, time.Millisecond, 2*time.Millisecond))
+	fallback, fallbackLogs := observer.New(zapcore.DebugLevel)
+	exporter.configure(fallback, "app")
+
+	exporter.enqueue(newOTLPEntry(zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: "hello"}, nil, false))
+	exporter.flush()
+
+	assert.Len(t, collector.received(), 3)
+	assert.Equal(t, 0, fallbackLogs.Len())
+}
+
+func TestOTLPExporter_Fallback(t *testing.T) {
+	collector := newFakeCollector(t, http.StatusBadRequest)
+	exporter := newOTLPExporter(collector.server.URL, OTLPRetry(3, time.Millisecond, time.Millisecond))
+	fallback, fallbackLogs := observer.New(zapcore.DebugLevel)
+	exporter.configure(fallback, "app")
+
+	exporter.enqueue(newOTLPEntry(zapcore.Entry{Level: zapcore.DebugLevel, Time: time.Now(), Message: "not on console"}, []zapcore.Field{zap.Error(errors.New("boom")), zap.Int("attempt", 2)}, false))
+	exporter.enqueue(newOTLPEntry(zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: "on console"}, nil, true))
+	exporter.flush()
+
+	// Bad requests are not retried
+	assert.Len(t, collector.received(), 1)
+
+	logs := fallbackLogs.AllUntimed()
+	require.Len(t, logs, 2)
+	assert.Equal(t, "unable to export log entries to the OTLP collector, writing them to the console", logs[0].Message)
+	assert.Equal(t, int64(2), logs[0].ContextMap()["count"])
+	assert.Equal(t, "not on console", logs[1].Message)
+	assert.Equal(t, map[string]interface{}{"attempt": int64(2), "error": "boom"}, logs[1].ContextMap())
+}
+
+func TestOTLPCore_UrgentEntriesNotRetried(t *testing.T) {
+	collector := newFakeCollector(t, http.StatusServiceUnavailable)
+	exporter := newOTLPExporter(collector.server.URL, OTLPRetry(3, time.Hour, time.Hour))
+	fallback, fallbackLogs := observer.New(zapcore.DebugLevel)
+	exporter.configure(fallback, "app")
+
+	core := newOTLPCore(exporter, zapcore.DebugLevel, zapcore.FatalLevel)
+	core.Write(zapcore.Entry{Level: zapcore.DPanicLevel, Time: time.Now(), Message: "about to exit"}, nil)
+
+	// A single attempt is made, the entry is then written to the fallback
+	assert.Len(t, collector.received(), 1)
+
+	logs := fallbackLogs.AllUntimed()
+	require.Len(t, logs, 2)
+	assert.Equal(t, "about to exit", logs[1].Message)
+}
+
+func TestOTLPCore_UrgentEntriesDeadline(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-unblock:
+		}
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	exporter := newOTLPExporter(server.URL)
+	fallback, fallbackLogs := observer.New(zapcore.DebugLevel)
+	exporter.configure(fallback, "app")
+
+	core := newOTLPCore(exporter, zapcore.DebugLevel, zapcore.FatalLevel)
+
+	start := time.Now()
+	core.Write(zapcore.Entry{Level: zapcore.PanicLevel, Time: time.Now(), Message: "about to exit"}, nil)
+
+	assert.Less(t, int64(time.Since(start)), int64(otlpUrgentExportTimeout+time.Second))
+	require.Equal(t, 2, fallbackLogs.Len())
+	assert.Equal(t, "about to exit", fallbackLogs.All()[1].Message)
+}
+
+func TestOTLPExporter_WithoutFallback(t *testing.T) {
+	collector := newFakeCollector(t, http.StatusBadRequest)
+	exporter := newOTLPExporter(collector.server.URL, OTLPWithoutFallback())
+	fallback, fallbackLogs := observer.New(zapcore.DebugLevel)
+	exporter.configure(fallback, "app")
+
+	exporter.enqueue(newOTLPEntry(zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: "hello"}, nil, false))
+	exporter.flush()
+
+	assert.Equal(t, 0, fallbackLogs.Len())
+	assert.Equal(t, uint64(1), exporter.dropped.Load())
+}
+
+func TestOTLPExporter_QueueFull(t *testing.T) {
+	collector := newFakeCollector(t)
+	exporter := newOTLPExporter(collector.server.URL, OTLPQueueSize(2), OTLPFlushInterval(time.Hour))
+	fallback, fallbackLogs := observer.New(zapcore.DebugLevel)
+	exporter.configure(fallback, "app")
+
+	for _, message := range []string{"first", "second", "third"} {
+		exporter.enqueue(newOTLPEntry(zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: message}, nil, false))
+	}
+
+	// The queue is bounded, the entry that doesn't fit is written to the fallback
+	require.Equal(t, 1, fallbackLogs.Len())
+	assert.Equal(t, "third", fallbackLogs.All()[0].Message)
+
+	exporter.stop()
+	assert.Len(t, collector.received(), 1)
+
+	// Once stopped, entries are written to the fallback
+	exporter.enqueue(newOTLPEntry(zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: "after stop"}, nil, false))
+	require.Equal(t, 2, fallbackLogs.Len())
+	assert.Equal(t, "after stop", fallbackLogs.All()[1].Message)
+}
+
+func TestInstantiate_OTLPSink(t *testing.T) {
+	collector := newFakeCollector(t)
+
+	registry := newRegistry("test", dbgZlog)
+	logger, _ := rootLogger(registry, "app", "github.com/acme/app")
+
+	instance, err := instantiate(registry, noEnv, newInstantiateOptions(
+		WithSink("otel", SinkToOTLP(collector.server.URL, OTLPJSON(), OTLPFlushInterval(time.Hour)), SinkLevel(zap.DebugLevel)),
+		WithProductionDetector(func() bool { return false }),
+	))
+	require.NoError(t, err)
+
+	logger.Debug("debug entry")
+	logger.Info("info entry")
+	assert.Len(t, collector.received(), 0)
+
+	require.NoError(t, instance.Close(context.Background()))
+
+	requests := collector.received()
+	require.Len(t, requests, 1)
+
+	var request map[string]interface{}
+	require.NoError(t, json.Unmarshal(requests[0].body, &request))
+
+	resourceLogs := request["resourceLogs"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "app"}},
+	}, resourceLogs["resource"].(map[string]interface{})["attributes"])
+
+	records := resourceLogs["scopeLogs"].([]interface{})[0].(map[string]interface{})["logRecords"].([]interface{})
+	require.Len(t, records, 2)
+	assert.Equal(t, map[string]interface{}{"stringValue": "debug entry"}, records[0].(map[string]interface{})["body"])
+	assert.Equal(t, map[string]interface{}{"stringValue": "info entry"}, records[1].(map[string]interface{})["body"])
+	assert.Equal(t, uint64(0), instance.DroppedLogEntries())
+}
+
+type collectorRequest struct {
+	contentType   string
+	authorization string
+	body          []byte
+}
+
+// fakeCollector is an in-process OTLP/HTTP collector answering the `statuses` in order, then
+// 200 OK.
+type fakeCollector struct {
+	server *httptest.Server
+
+	lock     sync.Mutex
+	statuses []int
+	requests []collectorRequest
+}
+
+func newFakeCollector(t *testing.T, statuses ...int) *fakeCollector {
+	t.Helper()
+
+	collector := &fakeCollector{statuses: statuses}
+	collector.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		collector.lock.Lock()
+		collector.requests = append(collector.requests, collectorRequest{r.Header.Get("Content-Type"), r.Header.Get("Authorization"), body})
+		status := http.StatusOK
+		if len(collector.statuses) > 0 {
+			status, collector.statuses = collector.statuses[0], collector.statuses[1:]
+		}
+		collector.lock.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(collector.server.Close)
+
+	return collector
+}
+
+func (c *fakeCollector) received() []collectorRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]collectorRequest(nil), c.requests...)
+}
+
+type protoField struct {
+	number uint64
+	data   []byte
+}
+
+// parseProto decodes the fields of a protobuf message by field number, the varint and fixed64
+// values being in `number` and the length-delimited ones in `data`.
+func parseProto(t *testing.T, message []byte) map[int][]protoField {
+	t.Helper()
+
+	out := map[int][]protoField{}
+	for len(message) > 0 {
+		tag, n := binary.Uvarint(message)
+		require.Greater(t, n, 0, "invalid tag")
+		message = message[n:]
+
+		var field protoField
+		switch tag & 7 {
+		case protoWireVarint:
+			field.number, n = binary.Uvarint(message)
+			require.Greater(t, n, 0, "invalid varint")
+			message = message[n:]
+		case protoWireFixed64:
+			require.GreaterOrEqual(t, len(message), 8, "invalid fixed64")
+			field.number = binary.LittleEndian.Uint64(message)
+			message = message[8:]
+		case protoWireBytes:
+			length, n := binary.Uvarint(message)
+			require.Greater(t, n, 0, "invalid length")
+			require.GreaterOrEqual(t, uint64(len(message)-n), length, "truncated field")
+			field.data = message[n : n+int(length)]
+			message = message[n+int(length):]
+		default:
+			require.Fail(t, "unexpected wire type", "%d", tag&7)
+		}
+
+		out[int(tag>>3)] = append(out[int(tag>>3)], field)
+	}
+
+	return out
+}
+
+func protoMessages(t *testing.T, message []byte, number int) (out [][]byte) {
+	t.Helper()
+
+	for _, field := range parseProto(t, message)[number] {
+		out = append(out, field.data)
+	}
+
+	return
+}
+
+// protoStringAttributes decodes the `KeyValue` fields holding string values.
+func protoStringAttributes(t *testing.T, message []byte, number int) (out [][2]string) {
+	t.Helper()
+
+	for _, kv := range protoMessages(t, message, number) {
+		fields := parseProto(t, kv)
+		out = append(out, [2]string{string(fields[1][0].data), string(protoMessages(t, fields[2][0].data, 1)[0])})
+	}
+
+	return
+}
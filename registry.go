@@ -16,12 +16,17 @@ package logging
 
 import (
 	"fmt"
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
+	"net/http"
 	"os"
-	"reflect"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var defaultLogger = zap.NewNop()
@@ -30,7 +35,7 @@ type loggerConfig struct {
 	isRootLogger   bool
 	shortName      string
 	defaultLevel   *zapcore.Level
-	isTraceEnabled *bool
+	isTraceEnabled *atomic.Bool
 	onUpdate       func(newLogger *zap.Logger)
 }
 
@@ -90,7 +95,7 @@ func loggerShortName(shortName string) LoggerOption {
 	})
 }
 
-func loggerWithTracer(isEnabled *bool) LoggerOption {
+func loggerWithTracer(isEnabled *atomic.Bool) LoggerOption {
 	return loggerOptionFunc(func(config *loggerConfig) {
 		config.isTraceEnabled = isEnabled
 	})
@@ -98,16 +103,48 @@ func loggerWithTracer(isEnabled *bool) LoggerOption {
 
 type LoggerExtender func(*zap.Logger) *zap.Logger
 
-type loggerFactory func(name string, level zap.AtomicLevel) *zap.Logger
+type loggerFactory func(shortName string, packageID string, level zap.AtomicLevel) *zap.Logger
 
 type registryEntry struct {
 	isRoot       bool
 	packageID    string
 	shortName    string
 	atomicLevel  zap.AtomicLevel
-	traceEnabled *bool
-	logPtr       *zap.Logger
+	traceEnabled *atomic.Bool
 	onUpdate     func(newLogger *zap.Logger)
+
+	// logger is the instance handed out to the developer, it never changes once registered,
+	// assigning a new logger to the entry atomically swaps the logger `core` delegates to instead.
+	logger *zap.Logger
+	core   *swappableCore
+
+	// lock serializes the level changes performed on the entry so that the level, the trace
+	// state, their origin and the pending revert of a time-limited override are always updated
+	// together.
+	lock          sync.Mutex
+	origin        LevelOrigin
+	pendingRevert *levelRevert
+
+	// baseline is the state of the entry once loggers have been instantiated (or at registration
+	// for entries registered afterward), see `ResetLevel`.
+	baseline levelState
+}
+
+// levelRevert is the level and trace state an entry reverts to once a time-limited level
+// override expires.
+type levelRevert struct {
+	level    zapcore.Level
+	trace    bool
+	origin   LevelOrigin
+	revertAt time.Time
+	timer    *time.Timer
+}
+
+func (e *registryEntry) getPendingRevert() *levelRevert {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.pendingRevert
 }
 
 func (e *registryEntry) String() string {
@@ -122,19 +159,22 @@ func (e *registryEntry) string(extended bool) string {
 
 	loggerPtr := "<nil>"
 	levels := ""
-	if e.logPtr != nil {
-		loggerPtr = fmt.Sprintf("%p", e.logPtr)
+	if e.logger != nil {
+		loggerPtr = fmt.Sprintf("%p", e.logger)
 		if extended {
-			levels = " [" + computeLevelsString(e.logPtr.Core()) + "]"
+			levels = " [" + computeLevelsString(e.core) + "]"
 		}
 	}
 
-	traceEnabled := false
-	if e.traceEnabled != nil {
-		traceEnabled = *e.traceEnabled
+	return fmt.Sprintf("%s @ %s (level: %s, trace?: %t, ptr: %s%s)", shortName, e.packageID, levelToString(e.atomicLevel.Level()), e.isTraceEnabled(), loggerPtr, levels)
+}
+
+func (e *registryEntry) isTraceEnabled() bool {
+	if e.traceEnabled == nil {
+		return false
 	}
 
-	return fmt.Sprintf("%s @ %s (level: %s, trace?: %t, ptr: %s%s)", shortName, e.packageID, e.atomicLevel.Level(), traceEnabled, loggerPtr, levels)
+	return e.traceEnabled.Load()
 }
 
 var zapLevels = []zapcore.Level{
@@ -160,17 +200,35 @@ func computeLevelsString(core zapcore.Core) string {
 	return strings.Join(levels, ", ")
 }
 
+// Register registers the logger pointed to by `zlogPtr` in the global registry. The logger is
+// updated in place (or the pointer assigned if `*zlogPtr` is `nil`) to a logger managed by the
+// registry which initially logs like the received logger (or not at all if `*zlogPtr` is `nil`).
+//
 // Deprecated: Use `var zlog, _ = logging.PackageLogger(<shortName>, "...")` instead.
 func Register(packageID string, zlogPtr **zap.Logger, options ...LoggerOption) {
+	if zlogPtr == nil {
+		panic("the zlog pointer (of type **zap.Logger) must be set")
+	}
+
+	logger := register(globalRegistry, packageID, *zlogPtr, options...)
 	if *zlogPtr == nil {
-		*zlogPtr = zap.NewNop()
+		*zlogPtr = logger
+		return
 	}
-	register(globalRegistry, packageID, *zlogPtr, options...)
+
+	// Updated in place so that the copies of the pointer taken before follow the registry too
+	**zlogPtr = *logger
 }
 
-func register(registry *registry, packageID string, zlogPtr *zap.Logger, options ...LoggerOption) {
-	if zlogPtr == nil {
-		panic("the zlog pointer (of type **zap.Logger) must be set")
+// register creates a new entry in the registry and returns the logger instance bound to it, the
+// returned logger initially logs like `initial` (or not at all if `nil`).
+func register(registry *registry, packageID string, initial *zap.Logger, options ...LoggerOption) *zap.Logger {
+	if initial == nil {
+		initial = zap.NewNop()
+	} else {
+		// `Register` overwrites the received logger with the returned one
+		initialCopy := *initial
+		initial = &initialCopy
 	}
 
 	config := loggerConfig{}
@@ -179,33 +237,40 @@ func register(registry *registry, packageID string, zlogPtr *zap.Logger, options
 	}
 
 	defaultLevel := zapcore.ErrorLevel
+	origin := LevelOrigin{Source: LevelSourceDefault}
 	if config.defaultLevel != nil {
 		defaultLevel = *config.defaultLevel
+		origin = LevelOrigin{Source: LevelSourceLoggerDefaultLevel}
 	}
 
+	logger, core := newSwappableLogger(strings.TrimSpace(config.shortName), initial)
+
 	entry := &registryEntry{
 		isRoot:       config.isRootLogger,
 		packageID:    packageID,
 		shortName:    config.shortName,
 		traceEnabled: config.isTraceEnabled,
 		atomicLevel:  zap.NewAtomicLevelAt(defaultLevel),
-		logPtr:       zlogPtr,
+		origin:       origin,
+		baseline:     levelState{level: defaultLevel, origin: origin},
 		onUpdate:     config.onUpdate,
+		logger:       logger,
+		core:         core,
 	}
 
 	registry.registerEntry(entry)
 
-	logger := defaultLogger
-	if zlogPtr != nil {
-		logger = zlogPtr
+	if entry.onUpdate != nil {
+		entry.onUpdate(logger)
 	}
 
-	// The tracing has already been set, so we can go unspecified here to not change anything
-	setLogger(entry, logger, unspecifiedTracing)
+	return logger
 }
 
 func Set(logger *zap.Logger, regexps ...string) {
-	for name, entry := range globalRegistry.entriesByPackageID {
+	globalRegistry.forAllEntries(func(entry *registryEntry) {
+		name := entry.packageID
+
 		if len(regexps) == 0 {
 			setLogger(entry, logger, unspecifiedTracing)
 		} else {
@@ -216,7 +281,7 @@ func Set(logger *zap.Logger, regexps ...string) {
 				}
 			}
 		}
-	}
+	})
 }
 
 // Extend is different than `Set` by being able to re-configure the existing logger set for
@@ -231,21 +296,17 @@ func Extend(extender LoggerExtender, regexps ...string) {
 }
 
 func extend(extender LoggerExtender, tracing tracingType, regexps ...string) {
-	for name, entry := range globalRegistry.entriesByPackageID {
-		if entry.logPtr == nil {
-			continue
-		}
-
+	globalRegistry.forAllEntries(func(entry *registryEntry) {
 		if len(regexps) == 0 {
-			setLogger(entry, extender(entry.logPtr), tracing)
+			setLogger(entry, extender(entry.core.logger()), tracing)
 		} else {
 			for _, re := range regexps {
-				if regexp.MustCompile(re).MatchString(name) {
-					setLogger(entry, extender(entry.logPtr), tracing)
+				if regexp.MustCompile(re).MatchString(entry.packageID) {
+					setLogger(entry, extender(entry.core.logger()), tracing)
 				}
 			}
 		}
-	}
+	})
 }
 
 // Override sets the given logger on previously registered and next
@@ -307,20 +368,21 @@ const (
 	disableTracing
 )
 
+// setLogger makes the entry's logger log like `logger`, using its core, name and options, the
+// entry's short name being used if `logger` has no name.
 func setLogger(entry *registryEntry, logger *zap.Logger, tracing tracingType) {
 	if entry == nil || logger == nil {
 		return
 	}
 
-	ve := reflect.ValueOf(entry.logPtr).Elem()
-	ve.Set(reflect.ValueOf(logger).Elem())
+	entry.core.swap(strings.TrimSpace(entry.shortName), logger)
 
 	if entry.traceEnabled != nil && tracing != unspecifiedTracing {
 		switch tracing {
 		case enableTracing:
-			*entry.traceEnabled = true
+			entry.traceEnabled.Store(true)
 		case disableTracing:
-			*entry.traceEnabled = false
+			entry.traceEnabled.Store(false)
 		}
 	}
 
@@ -336,8 +398,53 @@ type Registry interface {
 	GetLoggerByPackageID(packageID string) (*zap.Logger, Tracer, bool)
 }
 
+// LevelRegistry is the `Registry` returned by `NewRegistry`, it also manages the levels of the
+// loggers over time. It's a distinct interface so that the existing implementations of
+// `Registry` remain valid.
+type LevelRegistry interface {
+	Registry
+
+	SetLevelWithOptions(filterString string, level zapcore.Level, tracer bool, options ...SetLevelOption)
+	RevertLevel(filterString string)
+	ResetLevel(filterString string)
+	Explain(packageID string) (LevelExplanation, bool)
+	LevelSwitcherHandler(options ...SwitcherServerOption) http.Handler
+}
+
+type setLevelConfig struct {
+	ttl time.Duration
+}
+
+// SetLevelOption are option parameters that you can pass to `LevelRegistry.SetLevelWithOptions`.
+type SetLevelOption interface {
+	apply(config *setLevelConfig)
+}
+
+type setLevelOptionFunc func(config *setLevelConfig)
+
+func (f setLevelOptionFunc) apply(config *setLevelConfig) {
+	f(config)
+}
+
+// SetLevelTTL makes the level change temporary, once `ttl` has elapsed, each affected logger
+// automatically reverts to the level and trace state it had before the change. Use
+// `LevelRegistry.RevertLevel` to revert earlier.
+//
+// If a logger already has a pending revert, the new change extends it but the logger still
+// reverts to the state it had before the first time-limited change.
+func SetLevelTTL(ttl time.Duration) SetLevelOption {
+	return setLevelOptionFunc(func(config *setLevelConfig) {
+		config.ttl = ttl
+	})
+}
+
 type registry struct {
-	name               string
+	name string
+
+	// lock guards the factory, the entries maps and the root entry. Entries are never
+	// removed once registered, so they can safely be used after the lock is released,
+	// their own mutable state being protected independently.
+	lock               sync.RWMutex
 	factory            loggerFactory
 	entriesByPackageID map[string]*registryEntry
 	entriesByShortName map[string][]*registryEntry
@@ -347,7 +454,7 @@ type registry struct {
 	dbgLogger *zap.Logger
 }
 
-func NewRegistry(name string) Registry {
+func NewRegistry(name string) LevelRegistry {
 	return newRegistry(name, zap.NewNop())
 }
 
@@ -362,10 +469,10 @@ func newRegistry(name string, logger *zap.Logger) *registry {
 		dbgLogger:          registryLogger,
 	}
 
-	registry.factory = func(name string, level zap.AtomicLevel) *zap.Logger {
+	registry.factory = func(shortName string, packageID string, level zap.AtomicLevel) *zap.Logger {
 		loggerOptions := newInstantiateOptions()
 
-		return newLogger(registry.dbgLogger, name, level, &loggerOptions)
+		return newLogger(registry.dbgLogger, shortName, packageID, level, &loggerOptions)
 	}
 
 	return registry
@@ -382,34 +489,97 @@ func debugLoggerForLoggingLibrary() (*zap.Logger, Tracer) {
 	})
 
 	spec := newLogLevelSpecFromEnv("__LOGGING_")
-	registry.forAllEntriesMatchingSpec(spec, func(entry *registryEntry, level zapcore.Level, trace bool) {
-		registry.setLevelForEntry(entry, level, trace)
+	registry.forAllEntriesMatchingSpec(spec, func(entry *registryEntry, specForKey *levelSpec) {
+		registry.setLevelForEntryFromSpec(entry, specForKey)
 	})
 
 	return logger, tracer
 }
 
 func (r *registry) Register(shortName string, packageID string, options ...LoggerOption) (*zap.Logger, Tracer) {
-	logger := zap.NewNop()
-	tracer := boolTracer{new(bool)}
+	tracer := boolTracer{atomic.NewBool(false)}
 
 	allOptions := append([]LoggerOption{
 		loggerShortName(shortName),
 		loggerWithTracer(tracer.value),
 	}, options...)
 
-	register(r, packageID, logger, allOptions...)
+	logger := register(r, packageID, nil, allOptions...)
 
 	return logger, tracer
 }
 
 func (r *registry) GetLoggerByPackageID(packageID string) (*zap.Logger, Tracer, bool) {
-	if v, ok := r.entriesByPackageID[packageID]; ok {
-		return v.logPtr, &boolTracer{v.traceEnabled}, true
+	if v, ok := r.entryByPackageID(packageID); ok {
+		return v.logger, &boolTracer{v.traceEnabled}, true
 	}
 	return nil, nil, false
 }
 
+func (r *registry) entryByPackageID(packageID string) (*registryEntry, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	entry, found := r.entriesByPackageID[packageID]
+	return entry, found
+}
+
+// entriesForShortName returns a copy of the entries registered under `shortName`.
+func (r *registry) entriesForShortName(shortName string) []*registryEntry {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return append([]*registryEntry(nil), r.entriesByShortName[shortName]...)
+}
+
+// entriesForKey returns the entries registered under `key` as a short name or, if there is
+// none, the entry registered under `key` as a package ID.
+func (r *registry) entriesForKey(key string) []*registryEntry {
+	if entries := r.entriesForShortName(key); len(entries) > 0 {
+		return entries
+	}
+
+	if entry, found := r.entryByPackageID(key); found {
+		return []*registryEntry{entry}
+	}
+
+	return nil
+}
+
+// sortedEntries returns all the entries registered at the time of the call sorted by package ID.
+func (r *registry) sortedEntries() (out []*registryEntry) {
+	r.forAllEntries(func(entry *registryEntry) {
+		out = append(out, entry)
+	})
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].packageID < out[j].packageID
+	})
+
+	return
+}
+
+func (r *registry) getRootEntry() *registryEntry {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.rootEntry
+}
+
+func (r *registry) getFactory() loggerFactory {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.factory
+}
+
+func (r *registry) setFactory(factory loggerFactory) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.factory = factory
+}
+
 func (r *registry) registerEntry(entry *registryEntry) {
 	if entry == nil {
 		panic("refusing to add a nil registry entry")
@@ -418,6 +588,9 @@ func (r *registry) registerEntry(entry *registryEntry) {
 	id := validateEntryIdentifier("package ID", entry.packageID, false)
 	shortName := validateEntryIdentifier("short name", entry.shortName, true)
 
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
 	if actual := r.entriesByPackageID[id]; actual != nil {
 		panic(fmt.Sprintf("packageID %q is already registered", id))
 	}
@@ -447,44 +620,65 @@ func (r *registry) registerEntry(entry *registryEntry) {
 	r.dbgLogger.Info("registered entry", zap.String("short_name", shortName), zap.String("id", id))
 }
 
+// forAllEntries calls `callback` for each entry registered at the time of the call, the
+// callback is invoked without holding the registry lock so it's free to call back into it.
 func (r *registry) forAllEntries(callback func(entry *registryEntry)) {
+	r.lock.RLock()
+	entries := make([]*registryEntry, 0, len(r.entriesByPackageID))
 	for _, entry := range r.entriesByPackageID {
+		entries = append(entries, entry)
+	}
+	r.lock.RUnlock()
+
+	for _, entry := range entries {
 		callback(entry)
 	}
 }
 
 // forAllEntriesMatchingSpec iterate sequentially through the sorted spec
-func (r *registry) forAllEntriesMatchingSpec(spec *logLevelSpec, callback func(entry *registryEntry, level zapcore.Level, trace bool)) {
+func (r *registry) forAllEntriesMatchingSpec(spec *logLevelSpec, callback func(entry *registryEntry, spec *levelSpec)) {
 	for _, specForKey := range spec.sortedSpecs() {
-		if specForKey.key == "true" || specForKey.key == "*" {
-			for _, entry := range r.entriesByPackageID {
-				callback(entry, specForKey.level, specForKey.trace)
-			}
+		r.forEntriesMatchingSpec(specForKey, callback, spec.excludesFor(specForKey)...)
+	}
+}
 
-			continue
-		}
+// forEntriesMatchingSpec resolves the entries matching the spec but not matching any of the
+// `excludes` while holding the registry lock and then invokes `callback` on each of them once
+// the lock has been released.
+func (r *registry) forEntriesMatchingSpec(spec *levelSpec, callback func(entry *registryEntry, spec *levelSpec), excludes ...*levelSpec) {
+	r.lock.RLock()
+	entries := r.entriesMatchingSpec(spec)
+	if len(excludes) > 0 {
+		entries = r.withoutEntriesMatchingSpecs(entries, excludes)
+	}
+	r.lock.RUnlock()
 
-		r.forEntriesMatchingSpec(specForKey, callback)
+	for _, entry := range entries {
+		callback(entry, spec)
 	}
 }
 
-func (r *registry) forEntriesMatchingSpec(spec *levelSpec, callback func(entry *registryEntry, level zapcore.Level, trace bool)) {
+// entriesMatchingSpec must be called while holding the registry lock.
+func (r *registry) entriesMatchingSpec(spec *levelSpec) (out []*registryEntry) {
+	if spec.key == "true" || spec.key == "*" {
+		for _, entry := range r.entriesByPackageID {
+			out = append(out, entry)
+		}
+		return
+	}
+
 	r.dbgLogger.Debug("looking in short names to find spec key", zap.String("key", spec.key))
 	entries, found := r.entriesByShortName[spec.key]
 	if found {
 		r.dbgLogger.Debug("found logger in short names", zap.Int("count", len(entries)))
-		for _, entry := range entries {
-			callback(entry, spec.level, spec.trace)
-		}
-		return
+		return append(out, entries...)
 	}
 
 	r.dbgLogger.Debug("looking in package IDs to find spec key", zap.String("key", spec.key))
 	entry, found := r.entriesByPackageID[spec.key]
 	if found {
 		r.dbgLogger.Debug("found logger in package ID", zap.Stringer("entry", entry))
-		callback(entry, spec.level, spec.trace)
-		return
+		return append(out, entry)
 	}
 
 	r.dbgLogger.Debug("looking in package IDs by regex", zap.String("key", spec.key))
@@ -496,13 +690,48 @@ func (r *registry) forEntriesMatchingSpec(spec *levelSpec, callback func(entry *
 
 	for packageID, entry := range r.entriesByPackageID {
 		if regex.MatchString(packageID) {
-			callback(entry, spec.level, spec.trace)
+			out = append(out, entry)
+		}
+	}
+	return
+}
+
+// withoutEntriesMatchingSpecs must be called while holding the registry lock.
+func (r *registry) withoutEntriesMatchingSpecs(entries []*registryEntry, excludes []*levelSpec) (out []*registryEntry) {
+	excluded := map[*registryEntry]bool{}
+	for _, exclude := range excludes {
+		for _, entry := range r.entriesMatchingSpec(exclude) {
+			excluded[entry] = true
+		}
+	}
+
+	for _, entry := range entries {
+		if !excluded[entry] {
+			out = append(out, entry)
+		}
+	}
+
+	return
+}
+
+// unmatchedSpecs returns the elements of `spec`, including deny elements, that match no
+// registered entry.
+func (r *registry) unmatchedSpecs(spec *logLevelSpec) (out []*levelSpec) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, specForKey := range append(spec.sortedSpecs(), spec.sortedExcludes()...) {
+		if len(r.entriesMatchingSpec(specForKey)) == 0 {
+			out = append(out, specForKey)
 		}
 	}
+
+	return
 }
 
 func (r *registry) InstantiateLogger(packageID string) {
-	r.createLoggerForEntry(r.entriesByPackageID[packageID])
+	entry, _ := r.entryByPackageID(packageID)
+	r.createLoggerForEntry(entry)
 }
 
 func (r *registry) createLoggerForEntry(entry *registryEntry) {
@@ -512,14 +741,12 @@ func (r *registry) createLoggerForEntry(entry *registryEntry) {
 
 	r.dbgLogger.Info("creating logger on entry from registry factory",
 		zap.Stringer("to_level", entry.atomicLevel),
-		zap.Boolp("trace_enabled", entry.traceEnabled),
+		zap.Bool("trace_enabled", entry.isTraceEnabled()),
 		zap.Stringer("entry", entry),
 	)
 
-	logger := r.factory(entry.shortName, entry.atomicLevel)
-
-	ve := reflect.ValueOf(entry.logPtr).Elem()
-	ve.Set(reflect.ValueOf(logger).Elem())
+	logger := r.getFactory()(entry.shortName, entry.packageID, entry.atomicLevel)
+	entry.core.swap(strings.TrimSpace(entry.shortName), logger)
 
 	if entry.onUpdate != nil {
 		entry.onUpdate(logger)
@@ -527,40 +754,169 @@ func (r *registry) createLoggerForEntry(entry *registryEntry) {
 }
 
 func (r *registry) SetLevel(filterString string, level zapcore.Level, tracer bool) {
+	r.SetLevelWithOptions(filterString, level, tracer)
+}
+
+// SetLevelWithOptions is like `SetLevel` with options, like `SetLevelTTL` making the change
+// temporary.
+func (r *registry) SetLevelWithOptions(filterString string, level zapcore.Level, tracer bool, options ...SetLevelOption) {
+	config := setLevelConfig{}
+	for _, opt := range options {
+		opt.apply(&config)
+	}
+
 	r.forEntriesMatchingSpec(&levelSpec{
-		key:   filterString,
-		level: level,
-		trace: tracer,
-	}, func(entry *registryEntry, level zapcore.Level, trace bool) {
-		r.setLevelForEntry(entry, level, tracer)
+		key:    filterString,
+		level:  level,
+		trace:  tracer,
+		source: LevelSourceSetLevel,
+	}, func(entry *registryEntry, specForKey *levelSpec) {
+		r.setLevelForEntryFor(entry, specForKey.level, specForKey.trace, specForKey.origin(), config.ttl)
 	})
+}
 
+// RevertLevel immediately reverts the loggers matching `filterString` that have a pending
+// time-limited level override (see `SetLevelTTL`) to the state they had before the override.
+func (r *registry) RevertLevel(filterString string) {
+	r.forEntriesMatchingSpec(&levelSpec{key: filterString}, func(entry *registryEntry, _ *levelSpec) {
+		r.revertLevelForEntry(entry)
+	})
+}
+
+// ResetLevel sets the loggers matching `filterString` back to the level they had once loggers
+// were instantiated, cancelling any pending time-limited level override.
+func (r *registry) ResetLevel(filterString string) {
+	r.forEntriesMatchingSpec(&levelSpec{key: filterString}, func(entry *registryEntry, _ *levelSpec) {
+		r.resetLevelForEntry(entry)
+	})
 }
 
-func (r *registry) setLevelForEntry(entry *registryEntry, level zapcore.Level, trace bool) {
+// recordBaselines records the current state of all entries as the state `ResetLevel` restores.
+func (r *registry) recordBaselines() {
+	r.forAllEntries(func(entry *registryEntry) {
+		entry.lock.Lock()
+		defer entry.lock.Unlock()
+
+		entry.baseline = levelState{level: entry.atomicLevel.Level(), trace: entry.isTraceEnabled(), origin: entry.origin}
+	})
+}
+
+func (r *registry) resetLevelForEntry(entry *registryEntry) {
+	entry.lock.Lock()
+	baseline := entry.baseline
+	entry.lock.Unlock()
+
+	r.setLevelForEntry(entry, baseline.level, baseline.trace, baseline.origin)
+}
+
+// setLevelForEntry permanently sets the level of the entry, cancelling any pending revert
+// of a previous time-limited level override.
+func (r *registry) setLevelForEntry(entry *registryEntry, level zapcore.Level, trace bool, origin LevelOrigin) {
+	r.setLevelForEntryFor(entry, level, trace, origin, 0)
+}
+
+// setLevelForEntryFromSpec permanently sets the level of the entry to the one of `spec`.
+func (r *registry) setLevelForEntryFromSpec(entry *registryEntry, spec *levelSpec) {
+	r.setLevelForEntry(entry, spec.level, spec.trace, spec.origin())
+}
+
+// setLevelForEntryFor sets the level of the entry for `ttl` after which the entry reverts to the
+// state it had before, a `ttl` of 0 means the change is permanent.
+func (r *registry) setLevelForEntryFor(entry *registryEntry, level zapcore.Level, trace bool, origin LevelOrigin, ttl time.Duration) {
 	if entry == nil {
 		return
 	}
 
-	r.dbgLogger.Info("setting logger level", zap.Stringer("to_level", level), zap.Bool("trace_enabled", trace), zap.Stringer("entry", entry))
-	entry.atomicLevel.SetLevel(level)
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+
+	revert := entry.pendingRevert
+	if revert != nil {
+		revert.timer.Stop()
+		entry.pendingRevert = nil
+	}
+
+	if ttl > 0 {
+		if revert == nil {
+			revert = &levelRevert{level: entry.atomicLevel.Level(), trace: entry.isTraceEnabled(), origin: entry.origin}
+		} else {
+			// We keep reverting to the state before the first override but with a fresh timer
+			revert = &levelRevert{level: revert.level, trace: revert.trace, origin: revert.origin}
+		}
+
+		revert.revertAt = time.Now().Add(ttl)
+		revert.timer = time.AfterFunc(ttl, func() {
+			r.expireLevelForEntry(entry, revert)
+		})
+
+		entry.pendingRevert = revert
+		origin.TTL = ttl
+	}
+
+	r.dbgLogger.Info("setting logger level", zap.Stringer("to_level", level), zap.Bool("trace_enabled", trace), zap.Duration("ttl", ttl), zap.Stringer("entry", entry))
+	entry.setLevel(level, trace, origin)
+}
+
+// expireLevelForEntry is invoked when the timer of `revert` fires, it's a no-op if the revert
+// has been cancelled or superseded in between.
+func (r *registry) expireLevelForEntry(entry *registryEntry, revert *levelRevert) {
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+
+	if entry.pendingRevert != revert {
+		return
+	}
+
+	entry.pendingRevert = nil
+
+	r.dbgLogger.Info("time-limited logger level expired", zap.Stringer("to_level", revert.level), zap.Bool("trace_enabled", revert.trace), zap.Stringer("entry", entry))
+	entry.setLevel(revert.level, revert.trace, revert.origin)
+}
+
+// revertLevelForEntry returns true if the entry had a pending revert that has been applied.
+func (r *registry) revertLevelForEntry(entry *registryEntry) bool {
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+
+	revert := entry.pendingRevert
+	if revert == nil {
+		return false
+	}
+
+	revert.timer.Stop()
+	entry.pendingRevert = nil
+
+	r.dbgLogger.Info("reverting time-limited logger level", zap.Stringer("to_level", revert.level), zap.Bool("trace_enabled", revert.trace), zap.Stringer("entry", entry))
+	entry.setLevel(revert.level, revert.trace, revert.origin)
+	return true
+}
+
+// setLevel must be called while holding the entry's lock.
+func (e *registryEntry) setLevel(level zapcore.Level, trace bool, origin LevelOrigin) {
+	e.atomicLevel.SetLevel(level)
+	e.origin = origin
 
 	// It's possible for an entry to have no tracer registered, for example if the legacy
 	// register method is used. We must protect from this and not set anything.
-	if entry.traceEnabled != nil {
-		*entry.traceEnabled = trace
+	if e.traceEnabled != nil {
+		e.traceEnabled.Store(trace)
 	}
 }
 
 func (r *registry) dumpRegistryToLogger() {
-	r.dbgLogger.Info("dumping registry to logger", zap.Int("entries", len(r.entriesByPackageID)))
+	var entries []*registryEntry
+	r.forAllEntries(func(entry *registryEntry) {
+		entries = append(entries, entry)
+	})
 
-	for _, entry := range r.entriesByPackageID {
+	r.dbgLogger.Info("dumping registry to logger", zap.Int("entries", len(entries)))
+
+	for _, entry := range entries {
 		r.dbgLogger.Info("registered entry", zap.String("entry", entry.string(true)))
 	}
 
-	if r.rootEntry != nil {
-		r.dbgLogger.Info("registered root entry", zap.Stringer("entry", r.rootEntry))
+	if rootEntry := r.getRootEntry(); rootEntry != nil {
+		r.dbgLogger.Info("registered root entry", zap.Stringer("entry", rootEntry))
 	} else {
 		r.dbgLogger.Info("no root entry")
 	}
@@ -0,0 +1,324 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestRegistry_ConcurrentAccess is meant to be run with `go test -race`, it hammers the registry
+// concurrently through registration, spec application and the HTTP level switcher.
+func TestRegistry_ConcurrentAccess(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	handler := registry.LevelSwitcherHandler()
+
+	shortName := "concurrent_test"
+	packageID := func(i int) string {
+		return fmt.Sprintf("github.com/streamingfast/logging/tests/concurrent/%d", i)
+	}
+
+	var wg sync.WaitGroup
+	run := func(count int, work func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				work(i)
+			}
+		}()
+	}
+
+	run(100, func(i int) {
+		packageLogger(registry, shortName, packageID(i))
+	})
+
+	run(100, func(i int) {
+		spec := newLogLevelSpec(fakeEnv(map[string]string{
+			"DEBUG": shortName,
+			"TRACE": "github.com/streamingfast/logging/tests/concurrent/.*",
+		}))
+
+		registry.forAllEntriesMatchingSpec(spec, func(entry *registryEntry, specForKey *levelSpec) {
+			registry.setLevelForEntryFromSpec(entry, specForKey)
+		})
+	})
+
+	run(100, func(i int) {
+		registry.SetLevel(shortName, zap.InfoLevel, false)
+		registry.InstantiateLogger(packageID(i))
+	})
+
+	run(100, func(i int) {
+		level := "debug"
+		if i%2 == 0 {
+			level = "trace"
+		}
+
+		request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(fmt.Sprintf(`{"inputs":%q,"level":%q}`, shortName, level)))
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusOK, response.Code)
+	})
+
+	run(100, func(i int) {
+		if logger, tracer, found := registry.GetLoggerByPackageID(packageID(i)); found {
+			logger.With(zap.Int("i", i)).Check(zap.DebugLevel, "checked")
+			tracer.Enabled()
+		}
+
+		registry.forAllEntries(func(entry *registryEntry) {
+			_ = entry.string(true)
+		})
+	})
+
+	wg.Wait()
+
+	assert.Len(t, registry.entriesForShortName(shortName), 100)
+}
+
+func TestRegistry_LoggerHandleFollowsInstantiation(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	logger, _ := packageLogger(registry, "lib", "com/lib")
+
+	core, logs := observer.New(zap.DebugLevel)
+	registry.setFactory(func(shortName string, packageID string, level zap.AtomicLevel) *zap.Logger {
+		return zap.New(core)
+	})
+
+	logger.Info("before")
+	registry.InstantiateLogger("com/lib")
+	logger.Info("after")
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "after", logs.All()[0].Message)
+	assert.Equal(t, "lib", logs.All()[0].LoggerName)
+}
+
+func TestRegistry_ConcurrentLoggingWhileSwapping(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	logger, _ := packageLogger(registry, "lib", "com/lib")
+
+	core, logs := observer.New(zap.DebugLevel)
+	registry.setFactory(func(shortName string, packageID string, level zap.AtomicLevel) *zap.Logger {
+		return zap.New(core)
+	})
+	registry.InstantiateLogger("com/lib")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Info("message", zap.Int("worker", i))
+				logger.With(zap.Int("worker", i)).Debug("message")
+			}
+		}(i)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 100; j++ {
+			registry.InstantiateLogger("com/lib")
+			registry.forAllEntries(func(entry *registryEntry) {
+				setLogger(entry, zap.New(core), unspecifiedTracing)
+			})
+		}
+	}()
+
+	wg.Wait()
+
+	assert.Equal(t, 4*100*2, logs.Len())
+}
+
+func TestRegistry_SwappedLoggerOptions(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	logger, _ := packageLogger(registry, "sc", "com/sc")
+	derived := logger.With(zap.String("request", "abc"))
+
+	core, logs := observer.New(zap.DebugLevel)
+	registry.forAllEntries(func(entry *registryEntry) {
+		setLogger(entry, zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel)).Named("other"), unspecifiedTracing)
+	})
+
+	logger.Info("swapped")
+	logger.Error("failed")
+	derived.Named("sub").Info("derived")
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, "other", entries[0].LoggerName)
+	assert.True(t, entries[0].Caller.Defined)
+	assert.True(t, strings.HasSuffix(entries[0].Caller.File, "registry_test.go"), entries[0].Caller.File)
+	assert.Empty(t, entries[0].Stack)
+	assert.NotEmpty(t, entries[1].Stack)
+
+	// Loggers derived before the swap follow it
+	assert.Equal(t, "other.sub", entries[2].LoggerName)
+	assert.Equal(t, map[string]interface{}{"request": "abc"}, entries[2].ContextMap())
+
+	// Without the caller option, the caller is not added
+	registry.forAllEntries(func(entry *registryEntry) {
+		setLogger(entry, zap.New(core, zap.Development()), unspecifiedTracing)
+	})
+
+	logger.Info("without caller")
+	require.Equal(t, 4, logs.Len())
+	assert.Equal(t, "sc", logs.All()[3].LoggerName)
+	assert.False(t, logs.All()[3].Caller.Defined)
+	assert.Panics(t, func() { logger.DPanic("development") })
+}
+
+func TestRegistry_SwappedLoggerCallerSkipAndFatal(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	logger, _ := packageLogger(registry, "sc", "com/sc")
+
+	core, logs := observer.New(zap.DebugLevel)
+	registry.forAllEntries(func(entry *registryEntry) {
+		setLogger(entry, zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1), zap.OnFatal(zapcore.WriteThenPanic)), unspecifiedTracing)
+	})
+
+	infoThroughHelper(logger, "skipped")
+	_, _, line, _ := runtime.Caller(0)
+
+	require.Equal(t, 1, logs.Len())
+	caller := logs.All()[0].Caller
+	assert.True(t, strings.HasSuffix(caller.File, "registry_test.go"), caller.File)
+	assert.Equal(t, line-1, caller.Line)
+
+	assert.PanicsWithValue(t, "fatal", func() { logger.Fatal("fatal") })
+	assert.Equal(t, 2, logs.Len())
+
+	// Sugared loggers resolve the caller past their own frames
+	registry.forAllEntries(func(entry *registryEntry) {
+		setLogger(entry, zap.New(core, zap.AddCaller()), unspecifiedTracing)
+	})
+
+	logger.Sugar().Infow("sugared")
+	_, _, line, _ = runtime.Caller(0)
+
+	require.Equal(t, 3, logs.Len())
+	assert.Equal(t, line-1, logs.All()[2].Caller.Line)
+}
+
+func infoThroughHelper(logger *zap.Logger, message string) {
+	logger.Info(message)
+}
+
+func BenchmarkSwappableLogger(b *testing.B) {
+	for _, test := range []struct {
+		name    string
+		options []zap.Option
+	}{
+		{"without caller", nil},
+		{"with caller", []zap.Option{zap.AddCaller()}},
+	} {
+		swapped := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(io.Discard), zap.InfoLevel), test.options...).Named("bench")
+
+		registry := newRegistry("test", zap.NewNop())
+		logger, _ := packageLogger(registry, "bench", "com/bench")
+		registry.forAllEntries(func(entry *registryEntry) {
+			setLogger(entry, swapped, unspecifiedTracing)
+		})
+
+		b.Run(test.name+"/baseline", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				swapped.Info("message", zap.Int("i", i))
+			}
+		})
+
+		b.Run(test.name+"/swappable", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				logger.Info("message", zap.Int("i", i))
+			}
+		})
+
+		b.Run(test.name+"/disabled", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				logger.Debug("message", zap.Int("i", i))
+			}
+		})
+	}
+}
+
+func TestRegister_UpdatesLoggerInPlace(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	zlog := zap.New(zapcore.NewNopCore())
+	copied := zlog
+
+	Register("com/legacy/in_place", &zlog)
+	assert.Same(t, copied, zlog)
+
+	globalRegistry.forAllEntries(func(entry *registryEntry) {
+		if entry.packageID == "com/legacy/in_place" {
+			setLogger(entry, zap.New(core), unspecifiedTracing)
+		}
+	})
+
+	copied.Info("hello")
+	require.Equal(t, 1, logs.Len())
+}
+
+func TestRegistry_SetLevelTTL(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	logger, tracer := packageLogger(registry, "lib", "com/lib")
+	registry.InstantiateLogger("com/lib")
+
+	registry.SetLevelWithOptions("lib", zap.DebugLevel, true, SetLevelTTL(50*time.Millisecond))
+	assertLevelAndTraceEnabled(t, logger, zap.DebugLevel, tracer, traceShouldBeEnabled)
+
+	entry, _ := registry.entryByPackageID("com/lib")
+	revert := entry.getPendingRevert()
+	require.NotNil(t, revert)
+	assert.Equal(t, zap.ErrorLevel, revert.level)
+	assert.False(t, revert.trace)
+
+	require.Eventually(t, func() bool { return entry.getPendingRevert() == nil }, time.Second, 5*time.Millisecond)
+	assertLevelAndTraceEnabled(t, logger, zap.ErrorLevel, tracer, traceShouldBeDisabled)
+}
+
+func TestRegistry_SetLevelTTL_ExtendKeepsOriginalState(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	logger, tracer := packageLogger(registry, "lib", "com/lib")
+	registry.InstantiateLogger("com/lib")
+
+	registry.SetLevelWithOptions("lib", zap.InfoLevel, false, SetLevelTTL(time.Hour))
+	registry.SetLevelWithOptions("lib", zap.DebugLevel, true, SetLevelTTL(time.Hour))
+	assertLevelAndTraceEnabled(t, logger, zap.DebugLevel, tracer, traceShouldBeEnabled)
+
+	registry.RevertLevel("lib")
+	assertLevelAndTraceEnabled(t, logger, zap.ErrorLevel, tracer, traceShouldBeDisabled)
+
+	entry, _ := registry.entryByPackageID("com/lib")
+	assert.Nil(t, entry.getPendingRevert())
+}
+
+func TestRegistry_SetLevelTTL_PermanentChangeCancelsRevert(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	logger, tracer := packageLogger(registry, "lib", "com/lib")
+	registry.InstantiateLogger("com/lib")
+
+	registry.SetLevelWithOptions("lib", zap.DebugLevel, true, SetLevelTTL(20*time.Millisecond))
+	registry.SetLevel("lib", zap.InfoLevel, false)
+
+	entry, _ := registry.entryByPackageID("com/lib")
+	assert.Nil(t, entry.getPendingRevert())
+
+	time.Sleep(50 * time.Millisecond)
+	assertLevelAndTraceEnabled(t, logger, zap.InfoLevel, tracer, traceShouldBeDisabled)
+}
@@ -0,0 +1,529 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// backupTimeFormat is the timestamp appended to the name of the rotated files, like
+// `app-2021-03-04T05-06-07.000.log`.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+type fileOptions struct {
+	maxSize         int64
+	maxAge          time.Duration
+	maxBackups      int
+	compress        bool
+	rotateOnStartup bool
+	reopenOnSIGHUP  bool
+}
+
+// rotates returns true if any option requires the file to be written through a `rotatingFile`.
+func (o fileOptions) rotates() bool {
+	return o != fileOptions{}
+}
+
+// FileOption configures the rotation of the log file, see `WithOutputToFile`.
+type FileOption interface {
+	apply(o *fileOptions)
+}
+
+type fileOptionFunc func(o *fileOptions)
+
+func (f fileOptionFunc) apply(o *fileOptions) {
+	f(o)
+}
+
+// FileMaxSize rotates the log file once writing to it would make it bigger than `bytes`.
+func FileMaxSize(bytes int64) FileOption {
+	return fileOptionFunc(func(o *fileOptions) {
+		o.maxSize = bytes
+	})
+}
+
+// FileMaxAge deletes the rotated files older than `age`, based on the timestamp in their name, when
+// the file is opened and after each rotation.
+func FileMaxAge(age time.Duration) FileOption {
+	return fileOptionFunc(func(o *fileOptions) {
+		o.maxAge = age
+	})
+}
+
+// FileMaxBackups keeps at most `count` rotated files, deleting the oldest ones.
+func FileMaxBackups(count int) FileOption {
+	return fileOptionFunc(func(o *fileOptions) {
+		o.maxBackups = count
+	})
+}
+
+// FileCompress compresses the rotated files with gzip.
+func FileCompress() FileOption {
+	return fileOptionFunc(func(o *fileOptions) {
+		o.compress = true
+	})
+}
+
+// FileRotateOnStartup rotates the existing log file, if not empty, when it's opened so that each
+// run of the process starts a new file.
+func FileRotateOnStartup() FileOption {
+	return fileOptionFunc(func(o *fileOptions) {
+		o.rotateOnStartup = true
+	})
+}
+
+// FileReopenOnSIGHUP reopens the log file when the process receives a `SIGHUP` (Unix only),
+// which is what `logrotate` expects once it moved the file away (without `copytruncate`).
+func FileReopenOnSIGHUP() FileOption {
+	return fileOptionFunc(func(o *fileOptions) {
+		o.reopenOnSIGHUP = true
+	})
+}
+
+// sharedLogFile opens the log file once for all the loggers writing to it.
+type sharedLogFile struct {
+	path    string
+	options fileOptions
+
+	lock        sync.Mutex
+	opened      bool
+	syncer      zapcore.WriteSyncer
+	file        *rotatingFile
+	closeSyncer func()
+	err         error
+}
+
+func newSharedLogFile(path string, options ...FileOption) *sharedLogFile {
+	file := &sharedLogFile{path: path}
+	for _, opt := range options {
+		opt.apply(&file.options)
+	}
+
+	return file
+}
+
+// open returns the file writer, opening it on the first call following the creation of the
+// shared file or its closing.
+func (f *sharedLogFile) open() (zapcore.WriteSyncer, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.opened {
+		return f.syncer, f.err
+	}
+
+	f.opened = true
+	if !f.options.rotates() {
+		f.syncer, f.closeSyncer, f.err = createLogFileWriter(f.path)
+		return f.syncer, f.err
+	}
+
+	f.file, f.err = openRotatingFile(f.path, f.options, time.Now, zapcore.Lock(os.Stderr))
+	if f.err == nil {
+		f.syncer = f.file
+	}
+
+	return f.syncer, f.err
+}
+
+// close closes the file if it was opened, stopping the background compression and clean up of
+// the rotated files, the writes of the loggers created before fail afterward.
+func (f *sharedLogFile) close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.opened {
+		return nil
+	}
+
+	f.opened = false
+	defer func() { f.syncer, f.file, f.closeSyncer, f.err = nil, nil, nil, nil }()
+
+	switch {
+	case f.file != nil:
+		return f.file.Close()
+	case f.closeSyncer != nil:
+		f.closeSyncer()
+	}
+
+	return nil
+}
+
+// rotatingFile is a `zapcore.WriteSyncer` writing to a file rotated when it reaches its maximum
+// size, the rotated files are then compressed and cleaned up in the background.
+type rotatingFile struct {
+	path    string
+	options fileOptions
+	now     func() time.Time
+
+	// errorOutput receives the errors happening while compressing or cleaning up the rotated
+	// files in the background, like zap's `ErrorOutput` (`stderr`) receives its internal errors
+	errorOutput zapcore.WriteSyncer
+
+	lock   sync.Mutex
+	file   *os.File
+	size   int64
+	closed bool
+
+	millOnce sync.Once
+	millCh   chan struct{}
+	millDone chan struct{}
+}
+
+func openRotatingFile(path string, options fileOptions, now func() time.Time, errorOutput zapcore.WriteSyncer) (*rotatingFile, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("make directories for log file %q: %w", path, err)
+	}
+
+	f := &rotatingFile{path: path, options: options, now: now, errorOutput: errorOutput, millCh: make(chan struct{}, 1)}
+
+	if options.rotateOnStartup {
+		if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+			if err := os.Rename(path, f.backupPath()); err != nil {
+				return nil, fmt.Errorf("rotate log file %q: %w", path, err)
+			}
+		}
+	}
+
+	if err := f.openFile(); err != nil {
+		return nil, err
+	}
+
+	// The rotated files left by previous runs are cleaned up right away, a process that never
+	// rotates its file would otherwise keep them forever
+	f.triggerMill()
+
+	return f, nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return 0, fmt.Errorf("log file %q is closed", f.path)
+	}
+
+	// A previous rotation or reopening failed, let's try again
+	if f.file == nil {
+		if err := f.openFile(); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.options.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.options.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+func (f *rotatingFile) Sync() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	return f.file.Sync()
+}
+
+// Rotate moves the current file to a backup and starts a new one.
+func (f *rotatingFile) Rotate() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return fmt.Errorf("log file %q is closed", f.path)
+	}
+
+	return f.rotate()
+}
+
+// Reopen closes the file and opens it again, creating it if it was moved away.
+func (f *rotatingFile) Reopen() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return fmt.Errorf("log file %q is closed", f.path)
+	}
+
+	if err := f.closeFile(); err != nil {
+		return err
+	}
+
+	return f.openFile()
+}
+
+// Close closes the file and stops the background compression and clean up, writes following it
+// fail and closing it again does nothing.
+func (f *rotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return nil
+	}
+
+	f.closed = true
+	if f.millDone != nil {
+		close(f.millCh)
+		<-f.millDone
+	}
+
+	return f.closeFile()
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.closeFile(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.path, f.backupPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rotate log file %q: %w", f.path, err)
+	}
+
+	if err := f.openFile(); err != nil {
+		return err
+	}
+
+	f.triggerMill()
+	return nil
+}
+
+func (f *rotatingFile) openFile() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open log file %q: %w", f.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat log file %q: %w", f.path, err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	return nil
+}
+
+func (f *rotatingFile) closeFile() error {
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	if err != nil {
+		return fmt.Errorf("close log file %q: %w", f.path, err)
+	}
+
+	return nil
+}
+
+// backupPath returns the name of the rotated file, the current time being inserted between the
+// name and the extension of the log file, followed by a counter if a file was already rotated
+// within the same millisecond, like `app-2021-03-04T05-06-07.000.1.log`.
+func (f *rotatingFile) backupPath() string {
+	dir, prefix, ext := f.backupNameParts()
+	timestamp := f.now().UTC().Format(backupTimeFormat)
+
+	path := filepath.Join(dir, prefix+timestamp+ext)
+	for counter := 1; backupExists(path); counter++ {
+		path = filepath.Join(dir, prefix+timestamp+"."+strconv.Itoa(counter)+ext)
+	}
+
+	return path
+}
+
+// backupExists returns true if the rotated file exists, compressed or not.
+func backupExists(path string) bool {
+	for _, candidate := range []string{path, path + ".gz"} {
+		if _, err := os.Lstat(candidate); !os.IsNotExist(err) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *rotatingFile) backupNameParts() (dir string, prefix string, ext string) {
+	name := filepath.Base(f.path)
+	ext = filepath.Ext(name)
+
+	return filepath.Dir(f.path), strings.TrimSuffix(name, ext) + "-", ext
+}
+
+func (f *rotatingFile) triggerMill() {
+	if f.closed || !f.options.compress && f.options.maxAge == 0 && f.options.maxBackups == 0 {
+		return
+	}
+
+	f.millOnce.Do(func() {
+		f.millDone = make(chan struct{})
+
+		go func() {
+			defer close(f.millDone)
+
+			for range f.millCh {
+				if err := f.mill(); err != nil {
+					fmt.Fprintf(f.errorOutput, "%s logging: unable to compress or clean up rotated log files of %q: %v\n", f.now().UTC().Format(time.RFC3339), f.path, err)
+					f.errorOutput.Sync()
+				}
+			}
+		}()
+	})
+
+	select {
+	case f.millCh <- struct{}{}:
+	default:
+	}
+}
+
+type logFileBackup struct {
+	path      string
+	timestamp time.Time
+	counter   int
+}
+
+// mill compresses the rotated files and deletes the ones exceeding the maximum count or age.
+func (f *rotatingFile) mill() error {
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	var remaining []logFileBackup
+	for i, backup := range backups {
+		tooMany := f.options.maxBackups > 0 && i >= f.options.maxBackups
+		tooOld := f.options.maxAge > 0 && f.now().Sub(backup.timestamp) > f.options.maxAge
+
+		if tooMany || tooOld {
+			if err := os.Remove(backup.path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove rotated log file: %w", err)
+			}
+			continue
+		}
+
+		remaining = append(remaining, backup)
+	}
+
+	if !f.options.compress {
+		return nil
+	}
+
+	for _, backup := range remaining {
+		if strings.HasSuffix(backup.path, ".gz") {
+			continue
+		}
+
+		if err := compressFile(backup.path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backups returns the rotated files, most recent first.
+func (f *rotatingFile) backups() ([]logFileBackup, error) {
+	dir, prefix, ext := f.backupNameParts()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("list rotated log files: %w", err)
+	}
+
+	var backups []logFileBackup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		suffix := strings.TrimPrefix(strings.TrimSuffix(name, ".gz"), prefix)
+		if !strings.HasSuffix(suffix, ext) || len(suffix) < len(backupTimeFormat)+len(ext) {
+			continue
+		}
+		suffix = strings.TrimSuffix(suffix, ext)
+
+		t, err := time.Parse(backupTimeFormat, suffix[:len(backupTimeFormat)])
+		if err != nil {
+			continue
+		}
+
+		counter := 0
+		if rest := suffix[len(backupTimeFormat):]; rest != "" {
+			if counter, err = strconv.Atoi(strings.TrimPrefix(rest, ".")); err != nil || rest[0] != '.' || counter <= 0 {
+				continue
+			}
+		}
+
+		backups = append(backups, logFileBackup{path: filepath.Join(dir, name), timestamp: t, counter: counter})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].timestamp.Equal(backups[j].timestamp) {
+			return backups[i].counter > backups[j].counter
+		}
+
+		return backups[i].timestamp.After(backups[j].timestamp)
+	})
+
+	return backups, nil
+}
+
+func compressFile(path string) (err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open rotated log file: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("create compressed log file: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(path + ".gz")
+		}
+	}()
+
+	writer := gzip.NewWriter(out)
+	if _, err := io.Copy(writer, in); err != nil {
+		return fmt.Errorf("compress rotated log file: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("compress rotated log file: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("close compressed log file: %w", err)
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,237 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRotatingFile_MaxSize(t *testing.T) {
+	dir := t.TempDir()
+	clock := newFakeClock()
+
+	file, err := openRotatingFile(filepath.Join(dir, "app.log"), fileOptions{maxSize: 11}, clock.now, zapcore.AddSync(io.Discard))
+	require.NoError(t, err)
+	defer file.Close()
+
+	write(t, file, "12345\n")
+	write(t, file, "6789\n")
+	clock.advance(time.Second)
+	write(t, file, "abcdef\n")
+	clock.advance(time.Second)
+	write(t, file, "a line longer than the maximum size\n")
+
+	assert.Equal(t, map[string]string{
+		"app.log":                         "a line longer than the maximum size\n",
+		"app-2021-03-04T05-06-09.000.log": "abcdef\n",
+		"app-2021-03-04T05-06-08.000.log": "12345\n6789\n",
+	}, readDir(t, dir))
+}
+
+func TestRotatingFile_SameMillisecond(t *testing.T) {
+	dir := t.TempDir()
+	clock := newFakeClock()
+
+	file, err := openRotatingFile(filepath.Join(dir, "app.log"), fileOptions{maxSize: 4, maxBackups: 2}, clock.now, zapcore.AddSync(io.Discard))
+	require.NoError(t, err)
+
+	write(t, file, "abc\n")
+	write(t, file, "def\n")
+	write(t, file, "ghi\n")
+	write(t, file, "jkl\n")
+	require.NoError(t, file.Close())
+
+	// The rotations happen within the same millisecond, none overwrites the previous one and the
+	// most recent ones are kept
+	assert.Equal(t, map[string]string{
+		"app.log":                           "jkl\n",
+		"app-2021-03-04T05-06-07.000.2.log": "ghi\n",
+		"app-2021-03-04T05-06-07.000.1.log": "def\n",
+	}, readDir(t, dir))
+}
+
+func TestRotatingFile_MillErrors(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app-2021-03-01T00-00-00.000.log"), []byte("old\n"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "app-2021-03-01T00-00-00.000.log.gz"), 0755))
+
+	errorOutput := &memorySyncer{}
+	file, err := openRotatingFile(filepath.Join(dir, "app.log"), fileOptions{compress: true}, newFakeClock().now, errorOutput)
+	require.NoError(t, err)
+
+	write(t, file, "abc\n")
+	require.NoError(t, file.Rotate())
+	require.NoError(t, file.Close())
+
+	assert.Contains(t, errorOutput.String(), "logging: unable to compress or clean up rotated log files of")
+}
+
+func TestRotatingFile_MaxBackupsAndAge(t *testing.T) {
+	dir := t.TempDir()
+	clock := newFakeClock()
+
+	for _, name := range []string{"app-2021-03-01T00-00-00.000.log", "app-2021-03-04T05-06-00.000.log.gz", "app-2021-03-04T05-06-01.000.log", "app-notabackup.log", "other.log"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("old\n"), 0644))
+	}
+
+	file, err := openRotatingFile(filepath.Join(dir, "app.log"), fileOptions{maxSize: 4, maxBackups: 2, maxAge: 24 * time.Hour}, clock.now, zapcore.AddSync(io.Discard))
+	require.NoError(t, err)
+
+	write(t, file, "abc\n")
+	write(t, file, "def\n")
+	require.NoError(t, file.Close())
+
+	assert.Equal(t, map[string]string{
+		"app.log":                         "def\n",
+		"app-2021-03-04T05-06-07.000.log": "abc\n",
+		"app-2021-03-04T05-06-01.000.log": "old\n",
+		"app-notabackup.log":              "old\n",
+		"other.log":                       "old\n",
+	}, readDir(t, dir))
+}
+
+func TestRotatingFile_MaxAgeOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app-2021-03-01T00-00-00.000.log", "app-2021-03-04T05-06-01.000.log"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("old\n"), 0644))
+	}
+
+	// The file never rotates, the expired backups are still removed
+	file, err := openRotatingFile(filepath.Join(dir, "app.log"), fileOptions{maxAge: 24 * time.Hour}, newFakeClock().now, zapcore.AddSync(io.Discard))
+	require.NoError(t, err)
+
+	write(t, file, "abc\n")
+	require.NoError(t, file.Close())
+
+	assert.Equal(t, map[string]string{
+		"app.log":                         "abc\n",
+		"app-2021-03-04T05-06-01.000.log": "old\n",
+	}, readDir(t, dir))
+}
+
+func TestRotatingFile_CloseTwice(t *testing.T) {
+	file, err := openRotatingFile(filepath.Join(t.TempDir(), "app.log"), fileOptions{maxBackups: 1}, newFakeClock().now, zapcore.AddSync(io.Discard))
+	require.NoError(t, err)
+
+	require.NoError(t, file.Close())
+	assert.NotPanics(t, func() { assert.NoError(t, file.Close()) })
+}
+
+func TestRotatingFile_CompressAndRotateOnStartup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("previous run\n"), 0644))
+
+	file, err := openRotatingFile(path, fileOptions{compress: true, rotateOnStartup: true}, newFakeClock().now, zapcore.AddSync(io.Discard))
+	require.NoError(t, err)
+
+	write(t, file, "current run\n")
+	require.NoError(t, file.Close())
+
+	assert.Equal(t, map[string]string{
+		"app.log":                            "current run\n",
+		"app-2021-03-04T05-06-07.000.log.gz": "previous run\n",
+	}, readDir(t, dir))
+}
+
+func TestRotatingFile_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	file, err := openRotatingFile(path, fileOptions{reopenOnSIGHUP: true}, time.Now, zapcore.AddSync(io.Discard))
+	require.NoError(t, err)
+	defer file.Close()
+
+	write(t, file, "before\n")
+
+	// Like logrotate does, the file is moved away then the process is notified
+	require.NoError(t, os.Rename(path, path+".1"))
+	write(t, file, "moved\n")
+	require.NoError(t, file.Reopen())
+	write(t, file, "after\n")
+
+	assert.Equal(t, map[string]string{
+		"app.log":   "after\n",
+		"app.log.1": "before\nmoved\n",
+	}, readDir(t, dir))
+}
+
+func TestInstantiate_OutputToRotatedFile(t *testing.T) {
+	dir := t.TempDir()
+
+	registry := newRegistry("test", dbgZlog)
+	libLogger, _ := packageLogger(registry, "lib", "com/lib")
+	logger, _ := applicationLogger(registry, fakeEnv(map[string]string{"DLOG": "com/lib=info"}), "test", "com/test", WithOutputToFile(filepath.Join(dir, "app.log"), FileMaxSize(200), FileMaxBackups(1)), WithProductionDetector(func() bool { return false }))
+
+	for i := 0; i < 10; i++ {
+		logger.Info("application line")
+		libLogger.Info("library line")
+	}
+
+	// The clean up of the rotated files happens in the background
+	require.Eventually(t, func() bool { return len(readDir(t, dir)) == 2 }, time.Second, 5*time.Millisecond)
+	for name, content := range readDir(t, dir) {
+		assert.LessOrEqual(t, len(content), 200, name)
+	}
+}
+
+type fakeClock struct {
+	current time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{current: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)}
+}
+
+func (c *fakeClock) now() time.Time          { return c.current }
+func (c *fakeClock) advance(d time.Duration) { c.current = c.current.Add(d) }
+
+func write(t *testing.T, file *rotatingFile, content string) {
+	t.Helper()
+
+	_, err := file.Write([]byte(content))
+	require.NoError(t, err)
+}
+
+// readDir returns the content of the files of the directory by name, decompressing the `.gz`
+// ones.
+func readDir(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	out := map[string]string{}
+	for _, name := range names {
+		file, err := os.Open(filepath.Join(dir, name))
+		require.NoError(t, err)
+
+		var reader io.Reader = file
+		if filepath.Ext(name) == ".gz" {
+			reader, err = gzip.NewReader(file)
+			require.NoError(t, err)
+		}
+
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		file.Close()
+
+		out[name] = string(content)
+	}
+
+	return out
+}
@@ -0,0 +1,35 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package logging
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// installReopenOnSIGHUP reopens the file on each `SIGHUP` in the background until the returned
+// function is called.
+func installReopenOnSIGHUP(file *rotatingFile, logger *zap.Logger) (uninstall func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			if err := file.Reopen(); err != nil {
+				logger.Warn("unable to reopen log file", zap.String("path", file.path), zap.Error(err))
+				continue
+			}
+
+			logger.Debug("log file reopened by signal", zap.String("path", file.path))
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(signals)
+	}
+}
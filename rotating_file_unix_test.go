@@ -0,0 +1,37 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstantiate_FileReopenOnSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	registry := newRegistry("test", dbgZlog)
+	logger, _ := applicationLogger(registry, noEnv, "test", "com/test", WithOutputToFile(path, FileReopenOnSIGHUP()), WithProductionDetector(func() bool { return false }))
+
+	logger.Info("before rotation")
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+
+	require.Eventually(t, func() bool {
+		logger.Info("after rotation")
+
+		content, err := os.ReadFile(path)
+		return err == nil && strings.Contains(string(content), "after rotation")
+	}, time.Second, 5*time.Millisecond)
+
+	content, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	require.Contains(t, string(content), "before rotation")
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelSourceSignal is the level applied through the signals installed by `WithSignalLevelToggle`.
+const LevelSourceSignal = "signal"
+
+// verbositySteps lists the level and trace states from the least to the most verbose one.
+var verbositySteps = []levelState{
+	{level: offLevel},
+	{level: zapcore.FatalLevel},
+	{level: zapcore.PanicLevel},
+	{level: zapcore.DPanicLevel},
+	{level: zapcore.ErrorLevel},
+	{level: zapcore.WarnLevel},
+	{level: zapcore.InfoLevel},
+	{level: zapcore.DebugLevel},
+	{level: zapcore.DebugLevel, trace: true},
+}
+
+// moreVerbose returns the state one verbosity step above the received one, the most verbose
+// state (debug with trace enabled) is returned as-is.
+func moreVerbose(level zapcore.Level, trace bool) (zapcore.Level, bool) {
+	if trace {
+		return zapcore.DebugLevel, true
+	}
+
+	for _, step := range verbositySteps {
+		if step.level < level || (step.level == level && step.trace && !trace) {
+			return step.level, step.trace
+		}
+	}
+
+	return zapcore.DebugLevel, true
+}
+
+// levelToggler steps up the verbosity of the loggers sharing the root logger's short name (or of
+// all loggers if there is no root logger) and resets them to their baseline, the state they had
+// once instantiated, like `ResetLevel` does.
+type levelToggler struct {
+	registry *registry
+	logger   *zap.Logger
+}
+
+func newLevelToggler(registry *registry, logger *zap.Logger) *levelToggler {
+	return &levelToggler{registry: registry, logger: logger}
+}
+
+func (t *levelToggler) entries() []*registryEntry {
+	if rootEntry := t.registry.getRootEntry(); rootEntry != nil {
+		return t.registry.entriesForShortName(rootEntry.shortName)
+	}
+
+	return t.registry.sortedEntries()
+}
+
+func (t *levelToggler) stepUp() {
+	for _, entry := range t.entries() {
+		explanation := entry.explain()
+
+		level, trace := moreVerbose(explanation.Level, explanation.Trace)
+		t.registry.setLevelForEntry(entry, level, trace, LevelOrigin{Source: LevelSourceSignal})
+
+		t.logger.Info("log level stepped up by signal", zap.String("logger", entry.packageID), zap.String("level", levelToString(level)), zap.Bool("trace", trace))
+	}
+}
+
+func (t *levelToggler) reset() {
+	for _, entry := range t.entries() {
+		t.registry.resetLevelForEntry(entry)
+
+		explanation := entry.explain()
+		t.logger.Info("log level reset by signal", zap.String("logger", entry.packageID), zap.String("level", levelToString(explanation.Level)), zap.Bool("trace", explanation.Trace))
+	}
+}
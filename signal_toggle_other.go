@@ -0,0 +1,16 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package logging
+
+import (
+	"runtime"
+
+	"go.uber.org/zap"
+)
+
+func installSignalLevelToggle(toggler *levelToggler) (uninstall func()) {
+	toggler.logger.Warn("signal level toggle is not supported on this platform, ignoring it", zap.String("os", runtime.GOOS))
+
+	return func() {}
+}
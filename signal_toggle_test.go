@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestMoreVerbose(t *testing.T) {
+	tests := []struct {
+		level         zapcore.Level
+		trace         bool
+		expectedLevel zapcore.Level
+		expectedTrace bool
+	}{
+		{offLevel, false, zapcore.FatalLevel, false},
+		{zapcore.ErrorLevel, false, zapcore.WarnLevel, false},
+		{zapcore.WarnLevel, false, zapcore.InfoLevel, false},
+		{zapcore.InfoLevel, false, zapcore.DebugLevel, false},
+		{zapcore.DebugLevel, false, zapcore.DebugLevel, true},
+		{zapcore.DebugLevel, true, zapcore.DebugLevel, true},
+	}
+
+	for _, test := range tests {
+		t.Run(levelToString(test.level), func(t *testing.T) {
+			level, trace := moreVerbose(test.level, test.trace)
+
+			assert.Equal(t, test.expectedLevel, level)
+			assert.Equal(t, test.expectedTrace, trace)
+		})
+	}
+}
+
+func TestLevelToggler(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	pkgLogger, pkgTracer := packageLogger(registry, "test", "com/test/lib")
+	otherLogger, otherTracer := packageLogger(registry, "other", "com/other")
+	appLogger, appTracer := applicationLogger(registry, noEnv, "test", "com/test")
+
+	toggler := newLevelToggler(registry, zap.NewNop())
+
+	toggler.stepUp()
+	assertLevelAndTraceEnabled(t, appLogger, zap.DebugLevel, appTracer, traceShouldBeDisabled)
+	assertLevelAndTraceEnabled(t, pkgLogger, zap.DebugLevel, pkgTracer, traceShouldBeDisabled)
+	assertLevelAndTraceEnabled(t, otherLogger, zap.ErrorLevel, otherTracer, traceShouldBeDisabled)
+
+	toggler.stepUp()
+	toggler.stepUp()
+	assertLevelAndTraceEnabled(t, appLogger, zap.DebugLevel, appTracer, traceShouldBeEnabled)
+
+	explanation, _ := registry.Explain("com/test")
+	assert.Equal(t, LevelOrigin{Source: LevelSourceSignal}, explanation.Origin)
+
+	toggler.reset()
+	assertLevelAndTraceEnabled(t, appLogger, zap.InfoLevel, appTracer, traceShouldBeDisabled)
+	assertLevelAndTraceEnabled(t, pkgLogger, zap.InfoLevel, pkgTracer, traceShouldBeDisabled)
+
+	explanation, _ = registry.Explain("com/test")
+	assert.Equal(t, LevelOrigin{Source: LevelSourceRootLoggerFallback}, explanation.Origin)
+
+	// Loggers registered after the toggler was created are reset to their registry baseline
+	packageLogger(registry, "test", "com/test/late")
+	baseline, _ := registry.Explain("com/test/late")
+
+	toggler.stepUp()
+	explanation, _ = registry.Explain("com/test/late")
+	assert.Equal(t, LevelOrigin{Source: LevelSourceSignal}, explanation.Origin)
+
+	toggler.reset()
+	explanation, _ = registry.Explain("com/test/late")
+	assert.Equal(t, baseline.Level, explanation.Level)
+	assert.Equal(t, baseline.Origin, explanation.Origin)
+}
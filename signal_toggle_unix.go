@@ -0,0 +1,37 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package logging
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// installSignalLevelToggle handles the signals in the background until the returned function
+// is called.
+func installSignalLevelToggle(toggler *levelToggler) (uninstall func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		for sig := range signals {
+			switch sig {
+			case syscall.SIGUSR1:
+				toggler.stepUp()
+			case syscall.SIGUSR2:
+				toggler.reset()
+			}
+		}
+	}()
+
+	toggler.logger.Debug("signal level toggle installed", zap.Stringer("step_up_signal", syscall.SIGUSR1), zap.Stringer("reset_signal", syscall.SIGUSR2))
+
+	return func() {
+		signal.Stop(signals)
+		close(signals)
+	}
+}
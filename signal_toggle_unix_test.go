@@ -0,0 +1,24 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package logging
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestInstantiate_SignalLevelToggle(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	applicationLogger(registry, noEnv, "test", "com/test", WithSignalLevelToggle())
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	require.Eventually(t, func() bool { return levelOf(registry, "com/test") == zap.DebugLevel }, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	require.Eventually(t, func() bool { return levelOf(registry, "com/test") == zap.InfoLevel }, time.Second, 5*time.Millisecond)
+}
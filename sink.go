@@ -0,0 +1,296 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// sink is an additional destination of the log entries declared with `WithSink`, with its own
+// output, format, minimum level and selection of loggers.
+type sink struct {
+	name     string
+	output   sinkOutput
+	format   Format
+	level    *zapcore.Level
+	selector *sinkSelector
+}
+
+type sinkOutput struct {
+	writer  zapcore.WriteSyncer
+	file    *sharedLogFile
+	ring    *RingBuffer
+	syslog  *syslogOutput
+	journal *journalWriter
+	otlp    *otlpExporter
+}
+
+func (o sinkOutput) isDefined() bool {
+	return o.writer != nil || o.file != nil || o.ring != nil || o.syslog != nil || o.journal != nil || o.otlp != nil
+}
+
+// open returns the writer of the output.
+func (o sinkOutput) open() (zapcore.WriteSyncer, error) {
+	switch {
+	case o.file != nil:
+		return o.file.open()
+	case o.ring != nil:
+		return o.ring, nil
+	case o.syslog != nil:
+		return o.syslog.writer, nil
+	case o.journal != nil:
+		return o.journal, nil
+	}
+
+	return o.writer, nil
+}
+
+// levelEnabler returns the sink's minimum level if defined, the logger's `level` otherwise.
+func (s *sink) levelEnabler(level zap.AtomicLevel) zapcore.LevelEnabler {
+	if s.level != nil {
+		return *s.level
+	}
+
+	return level
+}
+
+// SinkOption configures a sink, see `WithSink`.
+type SinkOption interface {
+	apply(s *sink)
+}
+
+type sinkOptionFunc func(s *sink)
+
+func (f sinkOptionFunc) apply(s *sink) {
+	f(s)
+}
+
+// SinkToStderr writes the sink's entries to `stderr`.
+func SinkToStderr() SinkOption {
+	return SinkToWriter(os.Stderr)
+}
+
+// SinkToStdout writes the sink's entries to `stdout`.
+func SinkToStdout() SinkOption {
+	return SinkToWriter(os.Stdout)
+}
+
+// SinkToWriter writes the sink's entries to `writer`, which is locked so that entries are never
+// interleaved.
+func SinkToWriter(writer zapcore.WriteSyncer) SinkOption {
+	locked := zapcore.Lock(writer)
+
+	return sinkOptionFunc(func(s *sink) {
+		s.output = sinkOutput{writer: locked}
+	})
+}
+
+// SinkToFile writes the sink's entries to the file at `path`, accepting the same rotation options
+// as `WithOutputToFile`.
+func SinkToFile(path string, options ...FileOption) SinkOption {
+	if path == "" {
+		panic(fmt.Errorf("the sink file path is empty, this is not accepted as a valid option"))
+	}
+
+	file := newSharedLogFile(path, options...)
+
+	return sinkOptionFunc(func(s *sink) {
+		s.output = sinkOutput{file: file}
+	})
+}
+
+// SinkToRingBuffer keeps the last sink's entries in `ring`, see `NewRingBuffer`.
+func SinkToRingBuffer(ring *RingBuffer) SinkOption {
+	return sinkOptionFunc(func(s *sink) {
+		s.output = sinkOutput{ring: ring}
+	})
+}
+
+// SinkFormat is the format of the sink's entries, `FormatJSON` by default. The function panics if
+// the format is not a known one.
+func SinkFormat(format Format) SinkOption {
+	parsed, err := parseFormat(string(format))
+	if err != nil {
+		panic(err)
+	}
+
+	return sinkOptionFunc(func(s *sink) {
+		s.format = parsed
+	})
+}
+
+// SinkLevel is the minimum level of the entries written to the sink, regardless of the level of
+// the loggers. By default, the sink follows the level of each logger.
+func SinkLevel(level zapcore.Level) SinkOption {
+	return sinkOptionFunc(func(s *sink) {
+		s.level = &level
+	})
+}
+
+// SinkSelector restricts the loggers writing to the sink using a comma-separated list of keys in
+// the same syntax as the level specs, without the levels. A key is either `*`, a short name, a
+// package ID or a regex matched against the package ID, a key prefixed with `-` excludes the
+// loggers it matches, like `github.com/acme/p2p.*,-github.com/acme/p2p/noisy`. By default, all
+// the loggers write to the sink.
+func SinkSelector(selector string) SinkOption {
+	parsed := parseSinkSelector(selector)
+
+	return sinkOptionFunc(func(s *sink) {
+		s.selector = parsed
+	})
+}
+
+// WithSink declares a destination of the log entries in **addition** to the console and the log
+// file, identified by `name`, a sink declared again with the same name replacing the previous
+// one. The output is defined by one of `SinkToStderr`, `SinkToStdout`, `SinkToFile`,
+// `SinkToWriter`, `SinkToRingBuffer`, `SinkToSyslog`, `SinkToJournald` or `SinkToOTLP` while
+// `SinkFormat`, `SinkLevel` and `SinkSelector` control what the sink receives, for example to write the debug entries of the p2p loggers to
+// their own file while the console stays at info:
+//
+//	logging.WithSink("p2p", logging.SinkToFile("p2p.log"), logging.SinkLevel(zap.DebugLevel), logging.SinkSelector("github.com/acme/p2p.*"))
+//
+// The function panics if the name is empty or if no output is defined.
+func WithSink(name string, options ...SinkOption) InstantiateOption {
+	if name == "" {
+		panic(fmt.Errorf("the sink name is empty, this is not accepted as a valid option"))
+	}
+
+	s := &sink{name: name, format: FormatJSON}
+	for _, opt := range options {
+		opt.apply(s)
+	}
+
+	if !s.output.isDefined() {
+		panic(fmt.Errorf("sink %q has no output, use one of SinkToStderr, SinkToStdout, SinkToFile, SinkToWriter, SinkToRingBuffer, SinkToSyslog, SinkToJournald or SinkToOTLP", name))
+	}
+
+	return instantiateFuncOption(func(o *instantiateOptions) {
+		for i, existing := range o.sinks {
+			if existing.name == name {
+				o.sinks[i] = s
+				return
+			}
+		}
+
+		o.sinks = append(o.sinks, s)
+	})
+}
+
+// sinkSelector selects the loggers writing to a sink, see `SinkSelector`.
+type sinkSelector struct {
+	includes []sinkSelectorKey
+	excludes []sinkSelectorKey
+}
+
+type sinkSelectorKey struct {
+	key   string
+	regex *regexp.Regexp
+}
+
+func parseSinkSelector(selector string) *sinkSelector {
+	out := &sinkSelector{}
+	for _, element := range splitSpecElements(selector) {
+		if key, ok := denyElementKey(element.value); ok {
+			out.excludes = append(out.excludes, newSinkSelectorKey(key))
+			continue
+		}
+
+		out.includes = append(out.includes, newSinkSelectorKey(element.value))
+	}
+
+	return out
+}
+
+func newSinkSelectorKey(key string) sinkSelectorKey {
+	// A key that is not a valid regex can still match a short name or a package ID exactly
+	regex, _ := regexp.Compile(key)
+
+	return sinkSelectorKey{key: key, regex: regex}
+}
+
+func (k sinkSelectorKey) matches(shortName string, packageID string) bool {
+	if k.key == "*" || k.key == "true" || k.key == shortName || k.key == packageID {
+		return true
+	}
+
+	return packageID != "" && k.regex != nil && k.regex.MatchString(packageID)
+}
+
+// matches returns true if the logger should write to the sink, a `nil` selector matching all
+// loggers.
+func (s *sinkSelector) matches(shortName string, packageID string) bool {
+	if s == nil {
+		return true
+	}
+
+	for _, exclude := range s.excludes {
+		if exclude.matches(shortName, packageID) {
+			return false
+		}
+	}
+
+	if len(s.includes) == 0 {
+		return true
+	}
+
+	for _, include := range s.includes {
+		if include.matches(shortName, packageID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RingBuffer is a sink output keeping the last encoded entries in memory, for example to expose
+// the recent logs of a process on a debug endpoint, see `SinkToRingBuffer`.
+type RingBuffer struct {
+	lock    sync.Mutex
+	entries []string
+	next    int
+	full    bool
+}
+
+// NewRingBuffer creates a ring buffer keeping the last `size` entries, the function panics if
+// `size` is not positive.
+func NewRingBuffer(size int) *RingBuffer {
+	if size <= 0 {
+		panic(fmt.Errorf("the ring buffer size must be positive, got %d", size))
+	}
+
+	return &RingBuffer{entries: make([]string, size)}
+}
+
+// Write records `p` as one entry, zap writing each encoded entry in a single call.
+func (r *RingBuffer) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.entries[r.next] = string(p)
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+
+	return len(p), nil
+}
+
+func (r *RingBuffer) Sync() error {
+	return nil
+}
+
+// Entries returns the entries currently held, oldest first, as encoded by the sink's format.
+func (r *RingBuffer) Entries() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.full {
+		return append([]string(nil), r.entries[:r.next]...)
+	}
+
+	return append(append([]string(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+}
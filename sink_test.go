@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSinkSelector(t *testing.T) {
+	tests := []struct {
+		selector  string
+		shortName string
+		packageID string
+		expected  bool
+	}{
+		{"", "p2p", "github.com/acme/p2p", true},
+		{"*", "p2p", "github.com/acme/p2p", true},
+		{"p2p", "p2p", "github.com/acme/p2p", true},
+		{"github.com/acme/p2p", "p2p", "github.com/acme/p2p", true},
+		{"github.com/acme/p2p.*", "p2p", "github.com/acme/p2p/peers", true},
+		{"github.com/acme/p2p.*", "app", "github.com/acme/app", false},
+		{"-github.com/acme/p2p.*", "app", "github.com/acme/app", true},
+		{"-github.com/acme/p2p.*", "p2p", "github.com/acme/p2p", false},
+		{"github.com/acme/.*,-p2p", "p2p", "github.com/acme/p2p", false},
+		{"github.com/acme/.*,-p2p", "app", "github.com/acme/app", true},
+		{"github.com/acme/(", "app", "github.com/acme/app", false},
+		{"app", "logging", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.selector+" "+test.packageID, func(t *testing.T) {
+			assert.Equal(t, test.expected, parseSinkSelector(test.selector).matches(test.shortName, test.packageID))
+		})
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	ring := NewRingBuffer(3)
+	assert.Empty(t, ring.Entries())
+
+	for _, entry := range []string{"a\n", "b\n"} {
+		ring.Write([]byte(entry))
+	}
+	assert.Equal(t, []string{"a\n", "b\n"}, ring.Entries())
+
+	for _, entry := range []string{"c\n", "d\n", "e\n"} {
+		ring.Write([]byte(entry))
+	}
+	assert.Equal(t, []string{"c\n", "d\n", "e\n"}, ring.Entries())
+}
+
+func TestWithSink_Invalid(t *testing.T) {
+	assert.PanicsWithError(t, `sink "p2p" has no output, use one of SinkToStderr, SinkToStdout, SinkToFile, SinkToWriter, SinkToRingBuffer, SinkToSyslog, SinkToJournald or SinkToOTLP`, func() {
+		WithSink("p2p", SinkLevel(zap.DebugLevel))
+	})
+
+	assert.PanicsWithError(t, `unknown log format "xml", valid formats are stackdriver, json, text, logfmt, ecs, otel`, func() {
+		WithSink("p2p", SinkToStderr(), SinkFormat("xml"))
+	})
+}
+
+func TestInstantiate_Sinks(t *testing.T) {
+	p2pFile := filepath.Join(t.TempDir(), "p2p.log")
+	ring := NewRingBuffer(10)
+
+	registry := newRegistry("test", dbgZlog)
+	p2pLogger, _ := packageLogger(registry, "p2p", "github.com/acme/p2p/peers")
+	logger, _ := applicationLogger(registry, fakeEnv(map[string]string{"DLOG": "p2p=info"}), "app", "github.com/acme/app",
+		WithSink("p2p", SinkToFile(p2pFile), SinkFormat(FormatLogfmt), SinkLevel(zap.DebugLevel), SinkSelector("github.com/acme/p2p.*")),
+		WithSink("recent", SinkToRingBuffer(ring), SinkFormat(FormatLogfmt), SinkSelector("-p2p")),
+		WithProductionDetector(func() bool { return false }),
+	)
+
+	p2pLogger.Debug("peer connected")
+	p2pLogger.Info("peer count")
+	logger.Debug("application debug")
+	logger.Info("application info")
+
+	lines := readLogLines(t, p2pFile)
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `level=debug logger=p2p msg="peer connected"`)
+	assert.Contains(t, lines[1], `level=info logger=p2p msg="peer count"`)
+
+	entries := ring.Entries()
+	require.Len(t, entries, 1)
+	assert.Contains(t, entries[0], `level=info logger=app msg="application info"`)
+
+	// The sink level doesn't change the level of the logger itself
+	assert.Equal(t, zap.InfoLevel, levelOf(registry, "github.com/acme/p2p/peers"))
+}
@@ -9,21 +9,101 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+// Spec is a parsed log level specification, see `ParseLogLevelSpec`. It's applied when
+// loggers are instantiated with `WithSpec`.
+type Spec struct {
+	spec  *logLevelSpec
+	input string
+}
+
+func (s Spec) String() string {
+	if s.spec == nil {
+		return "<empty>"
+	}
+
+	return s.spec.String()
+}
+
+// ParseLogLevelSpec parses `input` using the same syntax as the `DLOG` environment variable,
+// i.e. either a single level applying to all loggers (`DLOG=debug`) or a comma-separated list
+// of `<key>=<level>` elements (`DLOG=github.com/acme/.*=debug,mypkg=trace`), optionally mixed
+// with deny elements excluding some loggers (`DLOG=github.com/acme/.*=debug,-github.com/acme/noisy`).
+//
+// Contrary to the environment variable parsing which skips them silently, malformed elements
+// are reported through the returned error, which is of type `SpecErrors` and lists each problem
+// along with the offset in `input` at which the offending element starts.
+func ParseLogLevelSpec(input string) (Spec, error) {
+	spec := newEmptyLogLevelSpec()
+	if errs := spec.fillDlog("", input); len(errs) > 0 {
+		return Spec{spec, input}, errs
+	}
+
+	return Spec{spec, input}, nil
+}
+
+// SpecError is a problem found in an element of a log level specification.
+type SpecError struct {
+	// Source is where the spec comes from, for example the environment variable name, it's
+	// empty when the spec comes from `ParseLogLevelSpec`.
+	Source string
+
+	// Offset is the byte offset at which the offending element starts in the spec's input.
+	Offset int
+
+	Element string
+	Reason  string
+}
+
+func (e *SpecError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("invalid element %q at offset %d: %s", e.Element, e.Offset, e.Reason)
+	}
+
+	return fmt.Sprintf("%s: invalid element %q at offset %d: %s", e.Source, e.Element, e.Offset, e.Reason)
+}
+
+// SpecErrors lists all the problems found in a log level specification.
+type SpecErrors []*SpecError
+
+func (e SpecErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
 type levelSpec struct {
 	key      string
 	level    zapcore.Level
 	trace    bool
 	ordering int
+
+	// source is where the spec element comes from, for example the environment variable name,
+	// and offset is the position at which the element starts in the source's value.
+	source string
+	offset int
+}
+
+func (l *levelSpec) origin() LevelOrigin {
+	return LevelOrigin{Source: l.source, Key: l.key, Ordering: l.ordering}
 }
 
 func (l *levelSpec) String() string {
-	return fmt.Sprintf("key %q (level %s, trace %t, order %d)", l.key, l.level, l.trace, l.ordering)
+	return fmt.Sprintf("key %q (level %s, trace %t, order %d)", l.key, levelToString(l.level), l.trace, l.ordering)
 }
 
 type logLevelSpec struct {
 	incrementingIndex int
 	byKey             map[string][]*levelSpec
 	byLevel           map[zapcore.Level][]*levelSpec
+
+	// excludesBySource holds the deny elements (`-<key>`) of the spec grouped by the source
+	// they were defined in. A deny element excludes the loggers it matches from all the elements
+	// of the same source, wherever it appears in the source, elements of other sources are not
+	// affected, see `excludesFor`.
+	excludesBySource map[string][]*levelSpec
 }
 
 func (l *logLevelSpec) String() string {
@@ -51,7 +131,21 @@ func (l *logLevelSpec) String() string {
 		byLevelSpecs = append(byLevelSpecs, fmt.Sprintf("%s => [%s]", level, strings.Join(keySpecStrings, ", ")))
 	}
 
-	return fmt.Sprintf("By Key (%s), By Level (%s)", strings.Join(byKeySpecs, " | "), strings.Join(byLevelSpecs, " | "))
+	if len(l.excludesBySource) == 0 {
+		return fmt.Sprintf("By Key (%s), By Level (%s)", strings.Join(byKeySpecs, " | "), strings.Join(byLevelSpecs, " | "))
+	}
+
+	excludeSpecs := make([]string, 0, len(l.excludesBySource))
+	for source, excludes := range l.excludesBySource {
+		keys := make([]string, len(excludes))
+		for i, exclude := range excludes {
+			keys[i] = exclude.key
+		}
+
+		excludeSpecs = append(excludeSpecs, fmt.Sprintf("%s => [%s]", source, strings.Join(keys, ", ")))
+	}
+
+	return fmt.Sprintf("By Key (%s), By Level (%s), Excludes (%s)", strings.Join(byKeySpecs, " | "), strings.Join(byLevelSpecs, " | "), strings.Join(excludeSpecs, " | "))
 }
 
 func envGetFromMap(mappings map[string]string) func(string) string {
@@ -73,7 +167,14 @@ func newLogLevelSpecFromEnv(prefix string) *logLevelSpec {
 }
 
 func newLogLevelSpec(envGet func(string) string) *logLevelSpec {
-	spec := &logLevelSpec{byKey: map[string][]*levelSpec{}, byLevel: map[zapcore.Level][]*levelSpec{}}
+	spec, _ := parseLogLevelSpec(envGet)
+	return spec
+}
+
+// parseLogLevelSpec is like `newLogLevelSpec` but also returns the problems found while
+// parsing, the returned spec contains all the valid elements regardless of the errors.
+func parseLogLevelSpec(envGet func(string) string) (*logLevelSpec, SpecErrors) {
+	spec := newEmptyLogLevelSpec()
 
 	// Ordering is important, a DEBUG will overrides ERROR if there is conflict(s)
 	spec.fillEnvFlat(zapcore.ErrorLevel, false, "ERROR", envGet)
@@ -83,87 +184,217 @@ func newLogLevelSpec(envGet func(string) string) *logLevelSpec {
 	spec.fillEnvFlat(zapcore.DebugLevel, false, "DEBUG", envGet)
 	spec.fillEnvFlat(zapcore.DebugLevel, true, "TRACE", envGet)
 
-	input := strings.TrimSpace(envGet("DLOG"))
-	if input != "" {
-		if strings.Contains(input, "=") {
-			spec.fillKeyValue(input)
-		} else {
-			level, traceEnabled, ok := valueToLevelAndTrace(input)
-			if ok {
-				spec.fillFlat(level, traceEnabled, ".*")
-			}
-		}
-	}
+	errs := spec.fillDlog("DLOG", envGet("DLOG"))
+
+	return spec, errs
+}
+
+func newEmptyLogLevelSpec() *logLevelSpec {
+	return &logLevelSpec{byKey: map[string][]*levelSpec{}, byLevel: map[zapcore.Level][]*levelSpec{}, excludesBySource: map[string][]*levelSpec{}}
+}
+
+// newFlatLogLevelSpec creates a spec where each key of the comma-separated `input` list
+// is set at `level`.
+func newFlatLogLevelSpec(source string, level zapcore.Level, trace bool, input string) *logLevelSpec {
+	spec := newEmptyLogLevelSpec()
+	spec.fillFlat(source, level, trace, input)
 
 	return spec
 }
 
-func (s *logLevelSpec) add(key string, level zapcore.Level, trace bool) {
+func (s *logLevelSpec) add(source string, offset int, key string, level zapcore.Level, trace bool) {
 	s.incrementingIndex++
-	s.byKey[key] = append(s.byKey[key], &levelSpec{key, level, trace, s.incrementingIndex})
+	s.byKey[key] = append(s.byKey[key], &levelSpec{
+		key:      key,
+		level:    level,
+		trace:    trace,
+		ordering: s.incrementingIndex,
+		source:   source,
+		offset:   offset,
+	})
+}
+
+func (s *logLevelSpec) exclude(source string, offset int, key string) {
+	s.excludesBySource[source] = append(s.excludesBySource[source], &levelSpec{
+		key:    key,
+		source: source,
+		offset: offset,
+	})
+}
+
+// excludesFor returns the deny elements that apply to `spec`, i.e. those defined in the same
+// source as `spec`.
+func (s *logLevelSpec) excludesFor(spec *levelSpec) []*levelSpec {
+	return s.excludesBySource[spec.source]
+}
+
+// sortedExcludes returns all the deny elements of the spec sorted by source and by offset.
+func (s *logLevelSpec) sortedExcludes() (excludes []*levelSpec) {
+	for _, sourceExcludes := range s.excludesBySource {
+		excludes = append(excludes, sourceExcludes...)
+	}
+
+	sort.Slice(excludes, func(i, j int) bool {
+		if excludes[i].source != excludes[j].source {
+			return excludes[i].source < excludes[j].source
+		}
+
+		return excludes[i].offset < excludes[j].offset
+	})
+
+	return
+}
+
+// denyElementKey returns the key of a deny element (`-<key>`) and true if `element` is one.
+// The lone `-` is not a deny element, it's an alias of the `panic` level.
+func denyElementKey(element string) (string, bool) {
+	if len(element) < 2 || element[0] != '-' {
+		return "", false
+	}
+
+	return strings.TrimSpace(element[1:]), true
 }
 
 func (s *logLevelSpec) fillEnvFlat(level zapcore.Level, trace bool, key string, envGet func(string) string) {
-	input := strings.TrimSpace(envGet(key))
-	if input != "" {
-		s.fillFlat(level, trace, input)
+	input := envGet(key)
+	if strings.TrimSpace(input) != "" {
+		s.fillFlat(key, level, trace, input)
 	}
 }
 
-// fillKeyValue parse the input received against the following format:
+// fillFlat parse the input received against the following format:
 //
 // ```
-// <key1>,<key2>,...
+// <key1>,<key2>,-<key3>,...
 // ```
 //
 // How actually the key are interpreted is not the responsibility of the
 // spec. The <key> can be any string as long as it does not contain "," and "="
-// while.
-func (s *logLevelSpec) fillFlat(level zapcore.Level, trace bool, input string) {
-	for _, key := range strings.Split(input, ",") {
-		key = strings.TrimSpace(key)
-		if key != "" {
-			s.add(key, level, trace)
+// while. A key prefixed with "-" is a deny element excluding the loggers it matches
+// from all the other keys of the input.
+func (s *logLevelSpec) fillFlat(source string, level zapcore.Level, trace bool, input string) {
+	for _, element := range splitSpecElements(input) {
+		if key, ok := denyElementKey(element.value); ok {
+			s.exclude(source, element.offset, key)
+			continue
 		}
+
+		s.add(source, element.offset, element.value, level, trace)
 	}
 }
 
+// fillDlog parse the input received which is either a single level applying to all
+// loggers or a list of `<key>=<level>` elements, see `fillKeyValue`. In both forms, deny
+// elements (`-<key>`) can be added to exclude some loggers, like in `debug,-mypkg`.
+func (s *logLevelSpec) fillDlog(source string, input string) SpecErrors {
+	if strings.TrimSpace(input) == "" {
+		return nil
+	}
+
+	if strings.Contains(input, "=") {
+		return s.fillKeyValue(source, input)
+	}
+
+	var levelElements []specElement
+	for _, element := range splitSpecElements(input) {
+		if key, ok := denyElementKey(element.value); ok {
+			s.exclude(source, element.offset, key)
+			continue
+		}
+
+		levelElements = append(levelElements, element)
+	}
+
+	if len(levelElements) == 0 {
+		return nil
+	}
+
+	if len(levelElements) != 1 {
+		return SpecErrors{{Source: source, Offset: levelElements[1].offset, Element: levelElements[1].value, Reason: "expected a single level or a list of <key>=<level> elements"}}
+	}
+
+	level, traceEnabled, ok := valueToLevelAndTrace(levelElements[0].value)
+	if !ok {
+		return SpecErrors{{Source: source, Offset: levelElements[0].offset, Element: levelElements[0].value, Reason: fmt.Sprintf("unknown level %q", levelElements[0].value)}}
+	}
+
+	s.add(source, levelElements[0].offset, ".*", level, traceEnabled)
+	return nil
+}
+
 // fillKeyValue parse the input received against the following format:
 //
 // ```
-// <key1>=<level>,<key2>=<level>,...
+// <key1>=<level>,<key2>=<level>,-<key3>,...
 // ```
 //
 // How actually the key are interpreted is not the responsibility of the
 // spec. The <key> can be any string as long as it does not contain "," and "="
-// while the <level> should be one of 'error', 'warn' (or 'warning'), 'info', 'debug'
-// or 'trace'.
-func (s *logLevelSpec) fillKeyValue(input string) {
-	for _, keyValue := range strings.Split(input, ",") {
-		keyValue = strings.TrimSpace(keyValue)
-		if keyValue == "" {
+// while the <level> should be one of the values accepted by `valueToLevelAndTrace`.
+// A key prefixed with "-" (without level) is a deny element, see `fillFlat`.
+//
+// Malformed elements are skipped and reported in the returned errors.
+func (s *logLevelSpec) fillKeyValue(source string, input string) (errs SpecErrors) {
+	for _, element := range splitSpecElements(input) {
+		invalid := func(reason string, args ...interface{}) {
+			errs = append(errs, &SpecError{Source: source, Offset: element.offset, Element: element.value, Reason: fmt.Sprintf(reason, args...)})
+		}
+
+		if key, ok := denyElementKey(element.value); ok && !strings.Contains(element.value, "=") {
+			s.exclude(source, element.offset, key)
 			continue
 		}
 
-		parts := strings.SplitN(keyValue, "=", 2)
+		parts := strings.SplitN(element.value, "=", 2)
 		if len(parts) != 2 {
+			invalid("expected <key>=<level>")
 			continue
 		}
 
 		key := strings.TrimSpace(parts[0])
 		value := strings.TrimSpace(parts[1])
 
-		if key == "" || value == "" {
+		if key == "" {
+			invalid("key is empty")
+			continue
+		}
+
+		if value == "" {
+			invalid("level is empty")
 			continue
 		}
 
 		level, trace, ok := valueToLevelAndTrace(value)
 		if !ok {
+			invalid("unknown level %q", value)
 			continue
 		}
 
-		s.add(key, level, trace)
+		s.add(source, element.offset, key, level, trace)
+	}
+
+	return
+}
+
+type specElement struct {
+	value  string
+	offset int
+}
+
+// splitSpecElements splits the comma-separated `input` into its trimmed non-empty elements
+// along with the byte offset at which each element starts in `input`.
+func splitSpecElements(input string) (out []specElement) {
+	offset := 0
+	for _, rawElement := range strings.Split(input, ",") {
+		value := strings.TrimSpace(rawElement)
+		if value != "" {
+			out = append(out, specElement{value, offset + strings.Index(rawElement, value)})
+		}
+
+		offset += len(rawElement) + 1
 	}
+
+	return
 }
 
 // sortedSpecs sorts the current `spec` instance by their `ordering` value, The `ordering`
@@ -186,6 +417,22 @@ func (s *logLevelSpec) sortedSpecs() (specs []*levelSpec) {
 	return
 }
 
+// offLevel is a level above all the levels defined by zap, a logger at this level never logs
+// anything.
+const offLevel = zapcore.FatalLevel + 1
+
+// levelToString is like `zapcore.Level#String` but knows about `offLevel`.
+func levelToString(level zapcore.Level) string {
+	if level >= offLevel {
+		return "off"
+	}
+
+	return level.String()
+}
+
+// valueToLevelAndTrace converts a level value as found in specs to the actual level and
+// trace state it represents. Accepted values (case insensitive) are 'trace', 'debug', 'info',
+// 'warn' (or 'warning'), 'error', 'dpanic', 'panic' (or '-'), 'fatal' and 'off' (or 'none').
 func valueToLevelAndTrace(input string) (level zapcore.Level, traceEnabled bool, ok bool) {
 	switch strings.ToLower(input) {
 	case "trace":
@@ -198,8 +445,14 @@ func valueToLevelAndTrace(input string) (level zapcore.Level, traceEnabled bool,
 		return zapcore.WarnLevel, false, true
 	case "error":
 		return zapcore.ErrorLevel, false, true
+	case "dpanic":
+		return zapcore.DPanicLevel, false, true
 	case "panic", "-":
 		return zapcore.PanicLevel, false, true
+	case "fatal":
+		return zapcore.FatalLevel, false, true
+	case "off", "none":
+		return offLevel, false, true
 	}
 
 	// Invalid case, the actual level is there but should not be considered
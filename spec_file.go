@@ -0,0 +1,265 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// parseSpecFile parses the content of a spec file, files with a `.yaml`, `.yml` or `.json`
+// extension are expected to contain a map of `<key>: <level>` entries while any other file is
+// expected to use the `DLOG` syntax where new lines can be used in place of commas and lines
+// starting with `#` are ignored.
+func parseSpecFile(path string, content []byte) (*logLevelSpec, SpecErrors) {
+	spec := newEmptyLogLevelSpec()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return spec, spec.fillMap(path, content)
+	default:
+		return spec, spec.fillDlog(path, dlogFileContentToInput(content))
+	}
+}
+
+// dlogFileContentToInput turns the lines of a `DLOG` syntax file into a single comma-separated
+// input, the byte offsets of the elements are preserved so errors point in the actual file.
+func dlogFileContentToInput(content []byte) string {
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines[i] = strings.Repeat(" ", len(line))
+		}
+	}
+
+	return strings.Join(lines, ",")
+}
+
+// fillMap parse the input received as a YAML (or JSON) map of `<key>: <level>` entries, the
+// entries are added in the order they appear in the input.
+func (s *logLevelSpec) fillMap(source string, content []byte) (errs SpecErrors) {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return SpecErrors{{Source: source, Element: "<document>", Reason: err.Error()}}
+	}
+
+	if len(document.Content) == 0 {
+		return nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return SpecErrors{{Source: source, Offset: nodeOffset(content, root), Element: root.Value, Reason: "expected a map of <key>: <level> entries"}}
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		keyNode, valueNode := root.Content[i], root.Content[i+1]
+		offset := nodeOffset(content, keyNode)
+		element := fmt.Sprintf("%s: %s", keyNode.Value, valueNode.Value)
+
+		key := strings.TrimSpace(keyNode.Value)
+		if key == "" {
+			errs = append(errs, &SpecError{Source: source, Offset: offset, Element: element, Reason: "key is empty"})
+			continue
+		}
+
+		if valueNode.Kind != yaml.ScalarNode {
+			errs = append(errs, &SpecError{Source: source, Offset: offset, Element: key, Reason: "level must be a string"})
+			continue
+		}
+
+		level, trace, ok := valueToLevelAndTrace(strings.TrimSpace(valueNode.Value))
+		if !ok {
+			errs = append(errs, &SpecError{Source: source, Offset: offset, Element: element, Reason: fmt.Sprintf("unknown level %q", valueNode.Value)})
+			continue
+		}
+
+		s.add(source, offset, key, level, trace)
+	}
+
+	return
+}
+
+// nodeOffset converts the 1-based line and column of the YAML node into a byte offset in `content`.
+func nodeOffset(content []byte, node *yaml.Node) int {
+	offset := 0
+	for line := 1; line < node.Line; line++ {
+		index := bytes.IndexByte(content[offset:], '\n')
+		if index == -1 {
+			break
+		}
+
+		offset += index + 1
+	}
+
+	return offset + node.Column - 1
+}
+
+type levelState struct {
+	level  zapcore.Level
+	trace  bool
+	origin LevelOrigin
+}
+
+// specFileWatcher applies the spec read from a file on top of the levels configured by the other
+// means and re-applies it each time the file's content changes.
+//
+// The first time an entry is affected by the file, its state is recorded so that it can be
+// restored once the file no longer affects it. The last spec successfully parsed remains in
+// effect when the file cannot be read or contains invalid elements.
+type specFileWatcher struct {
+	registry *registry
+	path     string
+	interval time.Duration
+	logger   *zap.Logger
+
+	// parse is `parseSpecFile`, replaced in tests
+	parse func(path string, content []byte) (*logLevelSpec, SpecErrors)
+
+	lastContent []byte
+	applied     map[*registryEntry]*levelSpec
+	baselines   map[*registryEntry]levelState
+}
+
+func newSpecFileWatcher(registry *registry, path string, interval time.Duration) *specFileWatcher {
+	return &specFileWatcher{
+		registry:  registry,
+		path:      path,
+		interval:  interval,
+		logger:    zap.NewNop(),
+		parse:     parseSpecFile,
+		applied:   map[*registryEntry]*levelSpec{},
+		baselines: map[*registryEntry]levelState{},
+	}
+}
+
+// read returns the spec contained in the file, `changed` is false when the content is the same
+// as the last time the file was read. The file being read in the background, a panic of the
+// parser on a malformed file is reported as an error of the spec instead of crashing the process.
+func (w *specFileWatcher) read() (spec *logLevelSpec, errs SpecErrors, changed bool, err error) {
+	content, err := os.ReadFile(w.path)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if w.lastContent != nil && bytes.Equal(content, w.lastContent) {
+		return nil, nil, false, nil
+	}
+
+	w.lastContent = content
+
+	defer func() {
+		if r := recover(); r != nil {
+			spec, changed = nil, true
+			errs = SpecErrors{{Source: w.path, Element: "<document>", Reason: fmt.Sprintf("unable to parse: %v", r)}}
+		}
+	}()
+
+	spec, errs = w.parse(w.path, content)
+
+	return spec, errs, true, nil
+}
+
+// reload reads the file and applies its spec if its content changed and is valid.
+func (w *specFileWatcher) reload() {
+	spec, errs, changed, err := w.read()
+	if err != nil {
+		w.logger.Warn("unable to read log level spec file, keeping last valid spec", zap.String("path", w.path), zap.Error(err))
+		return
+	}
+
+	if !changed {
+		return
+	}
+
+	if len(errs) > 0 {
+		w.logger.Warn("invalid log level spec file, keeping last valid spec", zap.String("path", w.path), zap.Error(errs))
+		return
+	}
+
+	w.apply(spec)
+}
+
+// watch polls the file in the background until the returned function is called.
+func (w *specFileWatcher) watch() (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(w.interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				w.reload()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// apply sets the level of the entries matched by `spec` and restores the entries that were
+// affected by the previously applied spec but are not anymore.
+func (w *specFileWatcher) apply(spec *logLevelSpec) {
+	matched := map[*registryEntry]*levelSpec{}
+	w.registry.forAllEntriesMatchingSpec(spec, func(entry *registryEntry, specForKey *levelSpec) {
+		matched[entry] = specForKey
+	})
+
+	for entry, specForKey := range matched {
+		previous, wasApplied := w.applied[entry]
+		if wasApplied && previous.level == specForKey.level && previous.trace == specForKey.trace {
+			continue
+		}
+
+		if _, found := w.baselines[entry]; !found {
+			explanation := entry.explain()
+			w.baselines[entry] = levelState{level: explanation.Level, trace: explanation.Trace, origin: explanation.Origin}
+		}
+
+		w.logger.Info("log level changed by spec file",
+			zap.String("path", w.path),
+			zap.String("logger", entry.packageID),
+			zap.String("key", specForKey.key),
+			zap.String("from_level", levelToString(entry.atomicLevel.Level())),
+			zap.String("to_level", levelToString(specForKey.level)),
+			zap.Bool("trace", specForKey.trace),
+		)
+
+		w.registry.setLevelForEntryFromSpec(entry, specForKey)
+	}
+
+	for entry := range w.applied {
+		if _, found := matched[entry]; found {
+			continue
+		}
+
+		baseline := w.baselines[entry]
+		delete(w.baselines, entry)
+
+		w.logger.Info("log level restored, logger no longer affected by spec file",
+			zap.String("path", w.path),
+			zap.String("logger", entry.packageID),
+			zap.String("from_level", levelToString(entry.atomicLevel.Level())),
+			zap.String("to_level", levelToString(baseline.level)),
+			zap.Bool("trace", baseline.trace),
+		)
+
+		w.registry.setLevelForEntry(entry, baseline.level, baseline.trace, baseline.origin)
+	}
+
+	w.applied = matched
+}
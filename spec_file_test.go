@@ -0,0 +1,201 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseSpecFile(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		content        string
+		expected       []*levelSpec
+		expectedErrors SpecErrors
+	}{
+		{
+			"dlog syntax",
+			"levels.spec",
+			"# Libraries\ncom/lib=debug\n\ncom/other=trace,app=warn\n",
+			[]*levelSpec{
+				{key: "com/lib", level: zapcore.DebugLevel, ordering: 1, source: "levels.spec", offset: 12},
+				{key: "com/other", level: zapcore.DebugLevel, trace: true, ordering: 2, source: "levels.spec", offset: 27},
+				{key: "app", level: zapcore.WarnLevel, ordering: 3, source: "levels.spec", offset: 43},
+			},
+			nil,
+		},
+		{
+			"dlog syntax single level",
+			"levels",
+			"info\n",
+			[]*levelSpec{{key: ".*", level: zapcore.InfoLevel, ordering: 1, source: "levels"}},
+			nil,
+		},
+		{
+			"dlog syntax invalid level",
+			"levels",
+			"com/lib=debug\ncom/other=debgu",
+			[]*levelSpec{{key: "com/lib", level: zapcore.DebugLevel, ordering: 1, source: "levels"}},
+			SpecErrors{{Source: "levels", Offset: 14, Element: "com/other=debgu", Reason: `unknown level "debgu"`}},
+		},
+		{
+			"yaml",
+			"levels.yaml",
+			"com/lib: debug\n\"com/other.*\": trace\n",
+			[]*levelSpec{
+				{key: "com/lib", level: zapcore.DebugLevel, ordering: 1, source: "levels.yaml"},
+				{key: "com/other.*", level: zapcore.DebugLevel, trace: true, ordering: 2, source: "levels.yaml", offset: 15},
+			},
+			nil,
+		},
+		{
+			"yaml invalid level",
+			"levels.yml",
+			"com/lib: debug\ncom/other: debgu\n",
+			[]*levelSpec{{key: "com/lib", level: zapcore.DebugLevel, ordering: 1, source: "levels.yml"}},
+			SpecErrors{{Source: "levels.yml", Offset: 15, Element: "com/other: debgu", Reason: `unknown level "debgu"`}},
+		},
+		{
+			"yaml not a map",
+			"levels.yaml",
+			"- debug\n",
+			nil,
+			SpecErrors{{Source: "levels.yaml", Offset: 0, Reason: "expected a map of <key>: <level> entries"}},
+		},
+		{
+			"json",
+			"levels.json",
+			`{"com/lib": "info", "app": "off"}`,
+			[]*levelSpec{
+				{key: "com/lib", level: zapcore.InfoLevel, ordering: 1, source: "levels.json", offset: 1},
+				{key: "app", level: offLevel, ordering: 2, source: "levels.json", offset: 20},
+			},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec, errs := parseSpecFile(test.path, []byte(test.content))
+			assert.Equal(t, test.expectedErrors, errs)
+
+			if len(test.expected) == 0 {
+				assert.Len(t, spec.sortedSpecs(), 0)
+			} else {
+				assert.Equal(t, test.expected, spec.sortedSpecs())
+			}
+		})
+	}
+}
+
+func TestInstantiate_SpecFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "levels.yaml")
+	writeSpecFile(t, path, "com/lib: debug\n")
+
+	registry := newRegistry("test", dbgZlog)
+	pkgLogger, pkgTracer := packageLogger(registry, "lib", "com/lib")
+	otherLogger, otherTracer := packageLogger(registry, "other", "com/other")
+	applicationLogger(registry, fakeEnv(map[string]string{"DLOG": "com/lib=warn,com/other=info"}), "test", "com/test", WithSpecFile(path), WithSpecFilePollInterval(5*time.Millisecond))
+
+	assertLevelAndTraceEnabled(t, pkgLogger, zap.DebugLevel, pkgTracer, traceShouldBeDisabled)
+	assertLevelAndTraceEnabled(t, otherLogger, zap.InfoLevel, otherTracer, traceShouldBeDisabled)
+
+	writeSpecFile(t, path, "com/other: trace\n")
+	require.Eventually(t, func() bool { return levelOf(registry, "com/other") == zap.DebugLevel }, time.Second, 5*time.Millisecond)
+
+	assertLevelAndTraceEnabled(t, pkgLogger, zap.WarnLevel, pkgTracer, traceShouldBeDisabled)
+	assertLevelAndTraceEnabled(t, otherLogger, zap.DebugLevel, otherTracer, traceShouldBeEnabled)
+
+	explanation, _ := registry.Explain("com/lib")
+	assert.Equal(t, LevelOrigin{Source: "DLOG", Key: "com/lib", Ordering: 1}, explanation.Origin)
+
+	// An invalid file keeps the last valid spec in effect
+	writeSpecFile(t, path, "com/other: debgu\n")
+	time.Sleep(50 * time.Millisecond)
+	assertLevelAndTraceEnabled(t, otherLogger, zap.DebugLevel, otherTracer, traceShouldBeEnabled)
+}
+
+func TestSpecFileWatcher_MalformedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "levels.yaml")
+	writeSpecFile(t, path, "com/lib: debug\n")
+
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+
+	watcher := newSpecFileWatcher(registry, path, time.Hour)
+	watcher.reload()
+	require.Equal(t, zap.DebugLevel, levelOf(registry, "com/lib"))
+
+	// Input making older YAML parsers panic (CVE-2022-28948)
+	writeSpecFile(t, path, "0: [:!00 \xef")
+	_, errs, changed, err := watcher.read()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.NotEmpty(t, errs)
+
+	// A parser panic keeps the last valid spec in effect
+	watcher.parse = func(string, []byte) (*logLevelSpec, SpecErrors) { panic("attempted to parse unknown event") }
+	writeSpecFile(t, path, "com/lib: info\n")
+
+	_, errs, changed, err = watcher.read()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "unable to parse: attempted to parse unknown event", errs[0].Reason)
+
+	writeSpecFile(t, path, "com/lib: warn\n")
+	watcher.reload()
+	assert.Equal(t, zap.DebugLevel, levelOf(registry, "com/lib"))
+}
+
+func TestSpecFileWatcher_Apply(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	packageLogger(registry, "other", "com/other")
+
+	watcher := newSpecFileWatcher(registry, "levels", time.Hour)
+	spec := func(content string) *logLevelSpec {
+		spec, errs := parseSpecFile("levels", []byte(content))
+		require.Len(t, errs, 0)
+		return spec
+	}
+
+	watcher.apply(spec("com/.*=debug"))
+	assert.Equal(t, zap.DebugLevel, levelOf(registry, "com/lib"))
+	assert.Equal(t, zap.DebugLevel, levelOf(registry, "com/other"))
+
+	registry.SetLevel("com/other", zap.WarnLevel, false)
+
+	// Unchanged elements are not re-applied
+	watcher.apply(spec("com/.*=debug\ncom/lib=info"))
+	assert.Equal(t, zap.InfoLevel, levelOf(registry, "com/lib"))
+	assert.Equal(t, zap.WarnLevel, levelOf(registry, "com/other"))
+
+	// Loggers no longer matched are restored to their state before the file affected them
+	watcher.apply(spec(""))
+	assert.Equal(t, zap.ErrorLevel, levelOf(registry, "com/lib"))
+	assert.Equal(t, zap.ErrorLevel, levelOf(registry, "com/other"))
+
+	explanation, _ := registry.Explain("com/lib")
+	assert.Equal(t, LevelOrigin{Source: LevelSourceDefault}, explanation.Origin)
+}
+
+func writeSpecFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	// The file is replaced atomically so that the watcher never reads it half-written
+	require.NoError(t, os.WriteFile(path+".tmp", []byte(content), 0644))
+	require.NoError(t, os.Rename(path+".tmp", path))
+}
+
+func levelOf(registry *registry, packageID string) zapcore.Level {
+	explanation, _ := registry.Explain(packageID)
+	return explanation.Level
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zapcore"
 )
 
@@ -19,7 +20,7 @@ func TestSortedEntriesFromEnv(t *testing.T) {
 				"DEBUG": "true",
 			}),
 			[]*levelSpec{
-				{key: "true", level: zapcore.DebugLevel, trace: false, ordering: 1},
+				{key: "true", level: zapcore.DebugLevel, trace: false, ordering: 1, source: "DEBUG"},
 			},
 		},
 		{
@@ -29,8 +30,8 @@ func TestSortedEntriesFromEnv(t *testing.T) {
 				"TRACE": "true",
 			}),
 			[]*levelSpec{
-				{key: "true", level: zapcore.DebugLevel, trace: false, ordering: 1},
-				{key: "true", level: zapcore.DebugLevel, trace: true, ordering: 2},
+				{key: "true", level: zapcore.DebugLevel, trace: false, ordering: 1, source: "DEBUG"},
+				{key: "true", level: zapcore.DebugLevel, trace: true, ordering: 2, source: "TRACE"},
 			},
 		},
 		{
@@ -39,7 +40,7 @@ func TestSortedEntriesFromEnv(t *testing.T) {
 				"TRACE": "*",
 			}),
 			[]*levelSpec{
-				{key: "*", level: zapcore.DebugLevel, trace: true, ordering: 1},
+				{key: "*", level: zapcore.DebugLevel, trace: true, ordering: 1, source: "TRACE"},
 			},
 		},
 	}
@@ -50,3 +51,157 @@ func TestSortedEntriesFromEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestLevelValuesFromDlog(t *testing.T) {
+	for _, test := range levelValueTests {
+		t.Run(test.value, func(t *testing.T) {
+			specs := newLogLevelSpec(fakeEnv(map[string]string{"DLOG": "lib=" + test.value})).sortedSpecs()
+
+			if test.expectedOk {
+				assert.Equal(t, []*levelSpec{
+					{key: "lib", level: test.expectedLevel, trace: test.expectedTrace, ordering: 1, source: "DLOG"},
+				}, specs)
+			} else {
+				assert.Len(t, specs, 0)
+			}
+		})
+	}
+}
+
+var levelValueTests = []struct {
+	value         string
+	expectedLevel zapcore.Level
+	expectedTrace bool
+	expectedOk    bool
+}{
+	{"trace", zapcore.DebugLevel, true, true},
+	{"debug", zapcore.DebugLevel, false, true},
+	{"info", zapcore.InfoLevel, false, true},
+	{"warn", zapcore.WarnLevel, false, true},
+	{"warning", zapcore.WarnLevel, false, true},
+	{"error", zapcore.ErrorLevel, false, true},
+	{"dpanic", zapcore.DPanicLevel, false, true},
+	{"panic", zapcore.PanicLevel, false, true},
+	{"-", zapcore.PanicLevel, false, true},
+	{"fatal", zapcore.FatalLevel, false, true},
+	{"off", offLevel, false, true},
+	{"none", offLevel, false, true},
+	{"ERROR", zapcore.ErrorLevel, false, true},
+	{"Off", offLevel, false, true},
+	{"debgu", 0, false, false},
+}
+
+func TestParseLogLevelSpec(t *testing.T) {
+	tests := []struct {
+		name           string
+		in             string
+		expected       []*levelSpec
+		expectedErrors SpecErrors
+	}{
+		{
+			"single level",
+			"debug",
+			[]*levelSpec{{key: ".*", level: zapcore.DebugLevel, ordering: 1}},
+			nil,
+		},
+		{
+			"key values",
+			"mypkg=debug, com/lib.*=trace",
+			[]*levelSpec{
+				{key: "mypkg", level: zapcore.DebugLevel, ordering: 1},
+				{key: "com/lib.*", level: zapcore.DebugLevel, trace: true, ordering: 2, offset: 13},
+			},
+			nil,
+		},
+		{
+			"unknown single level",
+			"  debgu",
+			nil,
+			SpecErrors{{Offset: 2, Element: "debgu", Reason: `unknown level "debgu"`}},
+		},
+		{
+			"unknown level",
+			"mypkg=debgu",
+			nil,
+			SpecErrors{{Offset: 0, Element: "mypkg=debgu", Reason: `unknown level "debgu"`}},
+		},
+		{
+			"invalid elements are reported, valid ones are kept",
+			"a=info,b,=debug,c=,d=warn",
+			[]*levelSpec{
+				{key: "a", level: zapcore.InfoLevel, ordering: 1},
+				{key: "d", level: zapcore.WarnLevel, ordering: 2, offset: 19},
+			},
+			SpecErrors{
+				{Offset: 7, Element: "b", Reason: "expected <key>=<level>"},
+				{Offset: 9, Element: "=debug", Reason: "key is empty"},
+				{Offset: 16, Element: "c=", Reason: "level is empty"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec, err := ParseLogLevelSpec(test.in)
+			if len(test.expectedErrors) == 0 {
+				require.NoError(t, err)
+			} else {
+				assert.Equal(t, test.expectedErrors, err)
+			}
+
+			specs := spec.spec.sortedSpecs()
+			if len(test.expected) == 0 {
+				assert.Len(t, specs, 0)
+			} else {
+				assert.Equal(t, test.expected, specs)
+			}
+		})
+	}
+}
+
+func TestParseLogLevelSpec_DenyElements(t *testing.T) {
+	tests := []struct {
+		name             string
+		in               string
+		expected         []*levelSpec
+		expectedExcludes []*levelSpec
+	}{
+		{
+			"single level",
+			"debug,-com/noisy",
+			[]*levelSpec{{key: ".*", level: zapcore.DebugLevel, ordering: 1}},
+			[]*levelSpec{{key: "com/noisy", offset: 6}},
+		},
+		{
+			"key values",
+			"-com/noisy,com/.*=debug",
+			[]*levelSpec{{key: "com/.*", level: zapcore.DebugLevel, ordering: 1, offset: 11}},
+			[]*levelSpec{{key: "com/noisy", offset: 0}},
+		},
+		{
+			"lone dash is the panic level",
+			"lib=-",
+			[]*levelSpec{{key: "lib", level: zapcore.PanicLevel, ordering: 1}},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec, err := ParseLogLevelSpec(test.in)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expected, spec.spec.sortedSpecs())
+			assert.Equal(t, test.expectedExcludes, spec.spec.sortedExcludes())
+		})
+	}
+}
+
+func TestSpecError(t *testing.T) {
+	err := SpecErrors{
+		{Source: "DLOG", Offset: 6, Element: "mypkg=debgu", Reason: `unknown level "debgu"`},
+		{Offset: 0, Element: "b", Reason: "expected <key>=<level>"},
+	}
+
+	assert.Equal(t, `DLOG: invalid element "mypkg=debgu" at offset 6: unknown level "debgu"; invalid element "b" at offset 0: expected <key>=<level>`, err.Error())
+}
@@ -0,0 +1,360 @@
+package logging
+
+import (
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// swappableCore is an indirection `zapcore.Core` whose actual logger can be replaced atomically
+// while other goroutines are logging through it. Every logger handed out by the registry is
+// built on top of such a core, see `newSwappableLogger`, re-instantiating a logger is then only
+// a matter of swapping the logger it delegates to.
+//
+// The whole `*zap.Logger` is kept so that its name and options (caller and caller skip, stack
+// traces, error output, development mode and fatal action) keep applying to the entries logged
+// through the handed out logger. Cores derived through `With` follow the swaps too.
+type swappableCore struct {
+	current *atomic.Value
+
+	// fields are those added through `With`, derived is the current core with the fields added,
+	// computed once per swapped logger
+	fields  []zapcore.Field
+	derived atomic.Value
+}
+
+// loggerHolder is the logger swapped in along with its name and options, probed once since
+// `zap.Logger` doesn't expose them.
+type loggerHolder struct {
+	logger      *zap.Logger
+	core        zapcore.Core
+	name        string
+	addCaller   bool
+	callerSkip  int
+	stackLevels map[zapcore.Level]bool
+	errorOutput zapcore.WriteSyncer
+	development bool
+	onFatal     zapcore.CheckWriteAction
+}
+
+type derivedCore struct {
+	holder *loggerHolder
+	core   zapcore.Core
+}
+
+// newSwappableLogger returns the logger handed out for an entry named `shortName`, logging
+// through `initial` until another logger is swapped in.
+func newSwappableLogger(shortName string, initial *zap.Logger) (*zap.Logger, *swappableCore) {
+	core := &swappableCore{current: &atomic.Value{}}
+	core.swap(shortName, initial)
+
+	// The handed out logger asks the swapped logger when to add a stack trace, the caller is
+	// resolved by the core only when the swapped logger adds it, see `Check`
+	logger := zap.New(core, zap.AddStacktrace(swappableStackLevel{core}), zap.ErrorOutput(swappableErrorOutput{core}))
+
+	return logger, core
+}
+
+func (c *swappableCore) holder() *loggerHolder {
+	return c.current.Load().(*loggerHolder)
+}
+
+// logger returns the logger currently swapped in.
+func (c *swappableCore) logger() *zap.Logger {
+	return c.holder().logger
+}
+
+func (c *swappableCore) load() (*loggerHolder, zapcore.Core) {
+	holder := c.holder()
+	if len(c.fields) == 0 {
+		return holder, holder.core
+	}
+
+	if derived, ok := c.derived.Load().(*derivedCore); ok && derived.holder == holder {
+		return holder, derived.core
+	}
+
+	core := holder.core.With(c.fields)
+	c.derived.Store(&derivedCore{holder, core})
+
+	return holder, core
+}
+
+// swap makes the core delegate to `logger`, named `shortName` if it has no name of its own.
+func (c *swappableCore) swap(shortName string, logger *zap.Logger) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	// Swapping a logger into itself (or into another indirection) would lead to an infinite
+	// recursion, this can happen if a `LoggerExtender` returns a handed out logger.
+	if other, ok := logger.Core().(*swappableCore); ok {
+		logger = other.logger()
+	}
+
+	holder := probeLogger(logger)
+	if holder.name == "" {
+		holder.name = shortName
+	}
+
+	c.current.Store(holder)
+}
+
+func (c *swappableCore) Enabled(level zapcore.Level) bool {
+	_, core := c.load()
+	return core.Enabled(level)
+}
+
+func (c *swappableCore) With(fields []zapcore.Field) zapcore.Core {
+	return &swappableCore{
+		current: c.current,
+		fields:  append(append([]zapcore.Field(nil), c.fields...), fields...),
+	}
+}
+
+func (c *swappableCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	holder, core := c.load()
+	entry.LoggerName = joinLoggerName(holder.name, entry.LoggerName)
+
+	if entry.Level == zapcore.FatalLevel {
+		// The core writes the fatal entries itself to perform the fatal action of the swapped
+		// logger, see `Write`
+		if core.Enabled(entry.Level) {
+			checked = checked.AddCore(entry, c)
+		}
+	} else {
+		// The cores of the swapped logger are added directly, they may have different levels,
+		// like a tee of the console and a sink
+		checked = core.Check(entry, checked)
+	}
+
+	// Only the entries about to be written pay for the caller, a logger asking for the caller
+	// itself through `zap.AddCaller` overwrites it
+	if checked != nil && holder.addCaller {
+		checked.Caller = resolveCaller(holder.callerSkip)
+	}
+
+	if entry.Level == zapcore.DPanicLevel && holder.development {
+		checked = checked.Should(entry, zapcore.WriteThenPanic)
+	}
+
+	return checked
+}
+
+// Write is only called for the fatal entries, see `Check`.
+func (c *swappableCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	holder, core := c.load()
+
+	if checked := core.Check(entry, nil); checked != nil {
+		checked.ErrorOutput = holder.errorOutput
+		checked.Write(fields...)
+	}
+
+	// The handed out logger exits once a fatal entry is written, the other actions of the
+	// swapped logger are performed here
+	switch holder.onFatal {
+	case zapcore.WriteThenPanic:
+		panic(entry.Message)
+	case zapcore.WriteThenGoexit:
+		runtime.Goexit()
+	}
+
+	return nil
+}
+
+// resolveCaller returns the caller of the zap logging method being called, skipping `skip`
+// additional frames like `zap.AddCallerSkip` does.
+func resolveCaller(skip int) zapcore.EntryCaller {
+	// Most of the time, `Logger.check` is called by the logging method called by the caller, only
+	// the frames from the logging method are captured then, their symbols being cached. Otherwise,
+	// like for the sugared loggers, the stack is searched from `Logger.check`.
+	var pcs [8]uintptr
+	if skip+2 <= len(pcs) {
+		if n := runtime.Callers(4, pcs[:2+skip]); n == 2+skip && frameOf(pcs[0]).inZap {
+			outOfZap := true
+			for _, pc := range pcs[1:n] {
+				outOfZap = outOfZap && !frameOf(pc).inZap
+			}
+
+			if outOfZap {
+				return frameOf(pcs[n-1]).caller
+			}
+		}
+	}
+
+	var morePCs [64]uintptr
+	caller, _ := findCaller(morePCs[:runtime.Callers(3, morePCs[:])], len(morePCs), skip)
+	return caller
+}
+
+type callerFrame struct {
+	inZap  bool
+	caller zapcore.EntryCaller
+}
+
+// callerFrames caches the frames by program counter, there are as many as logging call sites.
+var callerFrames sync.Map
+
+func frameOf(pc uintptr) *callerFrame {
+	if frame, found := callerFrames.Load(pc); found {
+		return frame.(*callerFrame)
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	cached := &callerFrame{
+		inZap:  strings.HasPrefix(frame.Function, "go.uber.org/zap."),
+		caller: zapcore.EntryCaller{Defined: frame.PC != 0, PC: frame.PC, File: frame.File, Line: frame.Line, Function: frame.Function},
+	}
+	callerFrames.Store(pc, cached)
+
+	return cached
+}
+
+// findCaller returns the frame `skip` frames above the first frame out of zap within `pcs`,
+// `found` is false if `pcs`, up to `limit` frames, doesn't go up that far.
+func findCaller(pcs []uintptr, limit int, skip int) (caller zapcore.EntryCaller, found bool) {
+	frames := runtime.CallersFrames(pcs)
+	inZap := false
+
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "go.uber.org/zap.") {
+			inZap = true
+		} else if inZap {
+			if skip == 0 {
+				return zapcore.EntryCaller{Defined: frame.PC != 0, PC: frame.PC, File: frame.File, Line: frame.Line, Function: frame.Function}, true
+			}
+			skip--
+		}
+
+		if !more {
+			return zapcore.EntryCaller{}, len(pcs) < limit
+		}
+	}
+}
+
+func (c *swappableCore) Sync() error {
+	_, core := c.load()
+	return core.Sync()
+}
+
+// swappableStackLevel enables the stack traces of the levels the swapped logger adds one to.
+type swappableStackLevel struct {
+	core *swappableCore
+}
+
+func (l swappableStackLevel) Enabled(level zapcore.Level) bool {
+	return l.core.holder().stackLevels[level]
+}
+
+// swappableErrorOutput writes to the error output of the swapped logger.
+type swappableErrorOutput struct {
+	core *swappableCore
+}
+
+func (o swappableErrorOutput) Write(p []byte) (int, error) {
+	return o.core.holder().errorOutput.Write(p)
+}
+
+func (o swappableErrorOutput) Sync() error {
+	return o.core.holder().errorOutput.Sync()
+}
+
+func joinLoggerName(parent string, name string) string {
+	switch {
+	case parent == "":
+		return name
+	case name == "":
+		return parent
+	}
+
+	return parent + "." + name
+}
+
+// probeLogger reads the name and options of `logger`, which `zap.Logger` doesn't expose, by
+// checking entries through a clone of it writing nowhere.
+func probeLogger(logger *zap.Logger) *loggerHolder {
+	probe := logger.WithOptions(zap.WrapCore(func(zapcore.Core) zapcore.Core { return probeCore{} }))
+
+	holder := &loggerHolder{logger: logger, core: logger.Core(), stackLevels: map[zapcore.Level]bool{}}
+	for _, level := range []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel, zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel} {
+		checked := probe.Check(level, "")
+		holder.name = checked.LoggerName
+		holder.errorOutput = checked.ErrorOutput
+		holder.stackLevels[level] = checked.Stack != ""
+
+		if level == zapcore.FatalLevel {
+			holder.onFatal = probeOnFatal(checked)
+		}
+	}
+
+	holder.addCaller, holder.callerSkip = probeCaller(probe)
+
+	if holder.errorOutput == nil {
+		holder.errorOutput = zapcore.AddSync(io.Discard)
+	}
+
+	// In development, DPanic entries panic once written
+	func() {
+		defer func() {
+			if recover() != nil {
+				holder.development = true
+			}
+		}()
+
+		probe.DPanic("")
+	}()
+
+	return holder
+}
+
+// probeCaller returns whether `probe` adds the caller and how many frames it skips, found by
+// looking for the caller it reports among the frames of this function's callers.
+func probeCaller(probe *zap.Logger) (addCaller bool, skip int) {
+	caller := probe.Check(zapcore.InfoLevel, "").Caller
+	if !caller.Defined {
+		return false, 0
+	}
+
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(1, pcs[:])])
+	for skip := 0; ; skip++ {
+		frame, more := frames.Next()
+		if frame.Function == caller.Function {
+			return true, skip
+		}
+
+		if !more {
+			return true, 0
+		}
+	}
+}
+
+// probeOnFatal returns the action performed once a fatal entry is written, `zapcore.CheckedEntry`
+// doesn't expose it either. The process exits if unknown.
+func probeOnFatal(checked *zapcore.CheckedEntry) zapcore.CheckWriteAction {
+	should := reflect.ValueOf(checked).Elem().FieldByName("should")
+	if !should.IsValid() || should.Kind() != reflect.Uint8 {
+		return zapcore.WriteThenFatal
+	}
+
+	return zapcore.CheckWriteAction(should.Uint())
+}
+
+// probeCore accepts all the entries and writes them nowhere.
+type probeCore struct{}
+
+func (probeCore) Enabled(zapcore.Level) bool                 { return true }
+func (c probeCore) With([]zapcore.Field) zapcore.Core        { return c }
+func (probeCore) Write(zapcore.Entry, []zapcore.Field) error { return nil }
+func (probeCore) Sync() error                                { return nil }
+
+func (c probeCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return checked.AddCore(entry, c)
+}
@@ -1,51 +1,410 @@
 package logging
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 type logChangeReq struct {
 	Inputs string `json:"inputs"`
 	Level  string `json:"level"`
+
+	// TTL is optional and, when set, must be a valid `time.ParseDuration` value after
+	// which the affected loggers revert to the level they had before the change.
+	TTL string `json:"ttl,omitempty"`
+}
+
+type loggersResp struct {
+	Loggers []loggerStatus `json:"loggers"`
+}
+
+type loggerStatus struct {
+	ShortName string       `json:"short_name"`
+	PackageID string       `json:"package_id"`
+	Level     string       `json:"level"`
+	Trace     bool         `json:"trace"`
+	Root      bool         `json:"root"`
+	Origin    originStatus `json:"origin"`
+
+	PendingRevert *pendingRevertStatus `json:"pending_revert,omitempty"`
+}
+
+type pendingRevertStatus struct {
+	Level    string       `json:"level"`
+	Trace    bool         `json:"trace"`
+	Origin   originStatus `json:"origin"`
+	RevertAt time.Time    `json:"revert_at"`
+}
+
+type originStatus struct {
+	Source   string `json:"source"`
+	Key      string `json:"key,omitempty"`
+	Ordering int    `json:"ordering,omitempty"`
+	TTL      string `json:"ttl,omitempty"`
+}
+
+func newLoggerStatus(entry *registryEntry) loggerStatus {
+	explanation := entry.explain()
+
+	status := loggerStatus{
+		ShortName: explanation.ShortName,
+		PackageID: explanation.PackageID,
+		Level:     levelToString(explanation.Level),
+		Trace:     explanation.Trace,
+		Root:      entry.isRoot,
+		Origin:    newOriginStatus(explanation.Origin),
+	}
+
+	if revert := explanation.PendingRevert; revert != nil {
+		status.PendingRevert = &pendingRevertStatus{
+			Level:    levelToString(revert.Level),
+			Trace:    revert.Trace,
+			Origin:   newOriginStatus(revert.Origin),
+			RevertAt: revert.RevertAt.UTC(),
+		}
+	}
+
+	return status
+}
+
+func newOriginStatus(origin LevelOrigin) originStatus {
+	status := originStatus{
+		Source:   origin.Source,
+		Key:      origin.Key,
+		Ordering: origin.Ordering,
+	}
+
+	if origin.TTL > 0 {
+		status.TTL = origin.TTL.String()
+	}
+
+	return status
+}
+
+// unixSocketAddrPrefix is the prefix of level switcher server listening addresses designating
+// a unix socket path instead of a TCP address.
+const unixSocketAddrPrefix = "unix://"
+
+// defaultSwitcherServerSocketPath is the per-process unix socket path the level switcher server
+// listens on when `WithLogLevelSwitcherServerUnixSocket` receives an empty path.
+func defaultSwitcherServerSocketPath() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("logging-%d.sock", os.Getpid()))
+}
+
+// listenSwitcherServer listens on `addr` which is either a TCP address or, when prefixed with
+// `unix://`, the path of a unix socket. A socket file left over by a previous process is
+// removed, but an error is returned if another process still listens on it.
+func listenSwitcherServer(addr string) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixSocketAddrPrefix) {
+		return net.Listen("tcp", addr)
+	}
+
+	path := strings.TrimPrefix(addr, unixSocketAddrPrefix)
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("unix socket %q is already in use by another process", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale unix socket %q: %w", path, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the user running the process can change its levels
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("restrict unix socket %q permissions: %w", path, err)
+	}
+
+	return listener, nil
+}
+
+// maxSwitcherServerRequestSize is the maximum size of the body of a level switcher server request.
+const maxSwitcherServerRequestSize = 64 * 1024
+
+type switcherServerConfig struct {
+	token       string
+	auditLogger *zap.Logger
+}
+
+// SwitcherServerOption are option parameters that you can pass to `LevelRegistry.LevelSwitcherHandler`.
+type SwitcherServerOption interface {
+	apply(config *switcherServerConfig)
+}
+
+type switcherServerOptionFunc func(config *switcherServerConfig)
+
+func (f switcherServerOptionFunc) apply(config *switcherServerConfig) {
+	f(config)
+}
+
+// SwitcherServerToken makes the level switcher server reject, with a `401 Unauthorized`, the
+// requests that do not carry the `Authorization: Bearer <token>` header, the scheme being
+// case-insensitive. An empty token disables the authentication.
+func SwitcherServerToken(token string) SwitcherServerOption {
+	return switcherServerOptionFunc(func(config *switcherServerConfig) {
+		config.token = token
+	})
+}
+
+// SwitcherServerAuditLogger configures the logger on which each change accepted by the level
+// switcher server is reported, defaults to a logger writing like the registry's root logger but
+// whose level is always info, the changes being reported even if the root logger's level is
+// higher.
+func SwitcherServerAuditLogger(logger *zap.Logger) SwitcherServerOption {
+	return switcherServerOptionFunc(func(config *switcherServerConfig) {
+		config.auditLogger = logger
+	})
 }
 
 type switcherServerHandler struct {
 	registry *registry
+	config   switcherServerConfig
+}
+
+// LevelSwitcherHandler returns the HTTP handler of the level switcher server acting on the
+// global registry, see `LevelRegistry.LevelSwitcherHandler` for details.
+func LevelSwitcherHandler(options ...SwitcherServerOption) http.Handler {
+	return globalRegistry.LevelSwitcherHandler(options...)
+}
+
+// LevelSwitcherHandler returns the HTTP handler of the level switcher server acting on this
+// registry. The handler expects to be served at the root path, to mount it under another path
+// of an existing mux, use `http.StripPrefix`:
+//
+//	mux.Handle("/logging/", http.StripPrefix("/logging", registry.LevelSwitcherHandler()))
+//
+// Levels are read with `GET`, changed with `PUT /` or `POST /`, time-limited changes are
+// reverted with `DELETE /` and loggers are reset to their instantiated level with `POST /reset`,
+// other methods are rejected and changes sent to other paths are answered with a
+// `404 Not Found`. Each accepted change is reported on the audit logger, see
+// `SwitcherServerAuditLogger`.
+func (r *registry) LevelSwitcherHandler(options ...SwitcherServerOption) http.Handler {
+	config := switcherServerConfig{}
+	for _, opt := range options {
+		opt.apply(&config)
+	}
+
+	return &switcherServerHandler{registry: r, config: config}
 }
 
 func (h *switcherServerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
-	defer r.Body.Close()
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="logging"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-	in := logChangeReq{}
-	if err := decoder.Decode(&in); err != nil {
-		http.Error(w, fmt.Sprintf("cannot unmarshal request: %s", err), 400)
+	isRoot := r.URL.Path == "" || r.URL.Path == "/"
+
+	switch r.Method {
+	case http.MethodGet:
+		h.serveLoggers(w, r)
+	case http.MethodPut, http.MethodPost:
+		switch {
+		case isRoot:
+			h.serveLevelChange(w, r)
+		case r.URL.Path == "/reset":
+			h.serveLevelReset(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	case http.MethodDelete:
+		if !isRoot {
+			http.NotFound(w, r)
+			return
+		}
+
+		h.serveLevelRevert(w, r)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// bearerScheme prefixes the token of the `Authorization` header.
+const bearerScheme = "Bearer "
+
+func (h *switcherServerHandler) authorized(r *http.Request) bool {
+	if h.config.token == "" {
+		return true
+	}
+
+	// The authentication scheme is case-insensitive, see RFC 6750 and RFC 7235
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) < len(bearerScheme) || !strings.EqualFold(authorization[:len(bearerScheme)], bearerScheme) {
+		return false
+	}
+
+	token := authorization[len(bearerScheme):]
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.config.token)) == 1
+}
+
+// auditLogger returns the logger on which the changes are reported. By default, it's a logger
+// created like the root logger but always at the info level, so that the changes are reported
+// whatever the level of the root logger is.
+func (h *switcherServerHandler) auditLogger() *zap.Logger {
+	if h.config.auditLogger != nil {
+		return h.config.auditLogger
+	}
+
+	if rootEntry := h.registry.getRootEntry(); rootEntry != nil {
+		return h.registry.getFactory()(rootEntry.shortName, rootEntry.packageID, zap.NewAtomicLevelAt(zapcore.InfoLevel))
+	}
+
+	return zap.NewNop()
+}
+
+// serveLoggers answers `GET /` with all registered loggers and `GET /loggers/<key>` with the
+// loggers registered under `<key>`, either as a short name or as a package ID.
+func (h *switcherServerHandler) serveLoggers(w http.ResponseWriter, r *http.Request) {
+	var entries []*registryEntry
+
+	switch {
+	case r.URL.Path == "" || r.URL.Path == "/":
+		entries = h.registry.sortedEntries()
+
+	case strings.HasPrefix(r.URL.Path, "/loggers/"):
+		key := strings.TrimPrefix(r.URL.Path, "/loggers/")
+		if entries = h.registry.entriesForKey(key); len(entries) == 0 {
+			http.Error(w, fmt.Sprintf("no logger registered under short name or package ID %q", key), 404)
+			return
+		}
+
+	default:
+		http.NotFound(w, r)
 		return
 	}
 
-	if in.Inputs == "" {
-		http.Error(w, "inputs not defined, should be comma-separated list of words or a regular expressions", 400)
+	out := loggersResp{Loggers: make([]loggerStatus, len(entries))}
+	for i, entry := range entries {
+		out.Loggers[i] = newLoggerStatus(entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		http.Error(w, fmt.Sprintf("cannot marshal response: %s", err), 500)
+	}
+}
+
+func (h *switcherServerHandler) serveLevelChange(w http.ResponseWriter, r *http.Request) {
+	in, ok := decodeLogChangeReq(w, r)
+	if !ok {
 		return
 	}
 
-	level := strings.ToUpper(in.Level)
-	if level != "WARN" && level != "WARNING" && level != "INFO" && level != "DEBUG" && level != "TRACE" {
-		http.Error(w, fmt.Sprintf("invalid level value %q", in.Level), 400)
+	var ttl time.Duration
+	if in.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(in.TTL); err != nil || ttl <= 0 {
+			http.Error(w, fmt.Sprintf("invalid ttl value %q, should be a positive duration like '10m'", in.TTL), 400)
+			return
+		}
+	}
+
+	level, trace, ok := valueToLevelAndTrace(strings.TrimSpace(in.Level))
+	if !ok {
+		http.Error(w, fmt.Sprintf("invalid level value %q, should be one of trace, debug, info, warn, error, dpanic, panic, fatal or off", in.Level), 400)
 		return
 	}
 
-	spec := newLogLevelSpec(envGetFromMap(map[string]string{
-		strings.ToUpper(in.Level): in.Inputs,
-	}))
+	var affected []string
+	spec := newFlatLogLevelSpec(LevelSourceSwitcherServer, level, trace, in.Inputs)
+	h.registry.forAllEntriesMatchingSpec(spec, func(entry *registryEntry, specForKey *levelSpec) {
+		h.registry.setLevelForEntryFor(entry, specForKey.level, specForKey.trace, specForKey.origin(), ttl)
+		affected = append(affected, entry.packageID)
+	})
+
+	h.auditLogger().Info("log level changed through level switcher server",
+		zap.String("remote_addr", r.RemoteAddr),
+		zap.String("inputs", in.Inputs),
+		zap.String("level", levelToString(level)),
+		zap.Bool("trace", trace),
+		zap.Duration("ttl", ttl),
+		zap.Strings("loggers", affected),
+	)
+
+	w.Write([]byte("ok"))
+}
+
+// serveLevelRevert reverts immediately the loggers matching the request's inputs that have a
+// pending time-limited level override, the request's level is ignored.
+func (h *switcherServerHandler) serveLevelRevert(w http.ResponseWriter, r *http.Request) {
+	in, ok := decodeLogChangeReq(w, r)
+	if !ok {
+		return
+	}
+
+	var affected []string
+	spec := newFlatLogLevelSpec(LevelSourceSwitcherServer, zapcore.DebugLevel, false, in.Inputs)
+	h.registry.forAllEntriesMatchingSpec(spec, func(entry *registryEntry, _ *levelSpec) {
+		if h.registry.revertLevelForEntry(entry) {
+			affected = append(affected, entry.packageID)
+		}
+	})
+
+	h.auditLogger().Info("log level reverted through level switcher server",
+		zap.String("remote_addr", r.RemoteAddr),
+		zap.String("inputs", in.Inputs),
+		zap.Strings("loggers", affected),
+	)
+
+	w.Write([]byte("ok"))
+}
+
+// serveLevelReset sets the loggers matching the request's inputs back to the level they had once
+// loggers were instantiated, the request's level is ignored.
+func (h *switcherServerHandler) serveLevelReset(w http.ResponseWriter, r *http.Request) {
+	in, ok := decodeLogChangeReq(w, r)
+	if !ok {
+		return
+	}
 
-	globalRegistry.forAllEntriesMatchingSpec(spec, func(entry *registryEntry, level zapcore.Level, trace bool) {
-		globalRegistry.setLevelForEntry(entry, level, trace)
+	var affected []string
+	spec := newFlatLogLevelSpec(LevelSourceSwitcherServer, zapcore.DebugLevel, false, in.Inputs)
+	h.registry.forAllEntriesMatchingSpec(spec, func(entry *registryEntry, _ *levelSpec) {
+		h.registry.resetLevelForEntry(entry)
+		affected = append(affected, entry.packageID)
 	})
 
+	h.auditLogger().Info("log level reset through level switcher server",
+		zap.String("remote_addr", r.RemoteAddr),
+		zap.String("inputs", in.Inputs),
+		zap.Strings("loggers", affected),
+	)
+
 	w.Write([]byte("ok"))
 }
+
+func decodeLogChangeReq(w http.ResponseWriter, r *http.Request) (in logChangeReq, ok bool) {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSwitcherServerRequestSize))
+	defer r.Body.Close()
+
+	if err := decoder.Decode(&in); err != nil {
+		http.Error(w, fmt.Sprintf("cannot unmarshal request: %s", err), 400)
+		return in, false
+	}
+
+	if in.Inputs == "" {
+		http.Error(w, "inputs not defined, should be comma-separated list of words or a regular expressions", 400)
+		return in, false
+	}
+
+	return in, true
+}
@@ -0,0 +1,403 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSwitcherServer_GetLoggers(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	packageLogger(registry, "lib", "com/lib/sub")
+	applicationLogger(registry, fakeEnv(map[string]string{"TRACE": "com/lib/sub"}), "app", "com/app")
+
+	handler := registry.LevelSwitcherHandler()
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expected       []loggerStatus
+	}{
+		{"all", "/", 200, []loggerStatus{
+			{ShortName: "app", PackageID: "com/app", Level: "info", Trace: false, Root: true, Origin: originStatus{Source: "root_logger_fallback"}},
+			{ShortName: "lib", PackageID: "com/lib", Level: "error", Trace: false, Root: false, Origin: originStatus{Source: "default"}},
+			{ShortName: "lib", PackageID: "com/lib/sub", Level: "debug", Trace: true, Root: false, Origin: originStatus{Source: "TRACE", Key: "com/lib/sub", Ordering: 1}},
+		}},
+		{"by short name", "/loggers/lib", 200, []loggerStatus{
+			{ShortName: "lib", PackageID: "com/lib", Level: "error", Trace: false, Root: false, Origin: originStatus{Source: "default"}},
+			{ShortName: "lib", PackageID: "com/lib/sub", Level: "debug", Trace: true, Root: false, Origin: originStatus{Source: "TRACE", Key: "com/lib/sub", Ordering: 1}},
+		}},
+		{"by package ID", "/loggers/com/lib/sub", 200, []loggerStatus{
+			{ShortName: "lib", PackageID: "com/lib/sub", Level: "debug", Trace: true, Root: false, Origin: originStatus{Source: "TRACE", Key: "com/lib/sub", Ordering: 1}},
+		}},
+		{"unknown key", "/loggers/com/unknown", 404, nil},
+		{"unknown path", "/unknown", 404, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			require.Equal(t, test.expectedStatus, response.Code, response.Body.String())
+			if test.expected == nil {
+				return
+			}
+
+			assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+
+			var out loggersResp
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &out))
+			assert.ElementsMatch(t, test.expected, out.Loggers)
+		})
+	}
+}
+
+func TestSwitcherServer_InvalidTTL(t *testing.T) {
+	handler := newRegistry("test", dbgZlog).LevelSwitcherHandler()
+
+	for _, ttl := range []string{"abc", "-1m", "0s"} {
+		t.Run(ttl, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"inputs":"lib","level":"debug","ttl":"`+ttl+`"}`)))
+
+			assert.Equal(t, 400, response.Code)
+		})
+	}
+}
+
+func TestSwitcherServer_Levels(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "levels_test", "com/levels")
+
+	entry, _ := registry.entryByPackageID("com/levels")
+	handler := registry.LevelSwitcherHandler()
+
+	for _, test := range levelValueTests {
+		t.Run(test.value, func(t *testing.T) {
+			registry.setLevelForEntry(entry, zapcore.InfoLevel, false, LevelOrigin{Source: LevelSourceSetLevel})
+
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"inputs":"levels_test","level":"`+test.value+`"}`)))
+
+			if !test.expectedOk {
+				assert.Equal(t, 400, response.Code)
+				assert.Equal(t, zapcore.InfoLevel, entry.atomicLevel.Level())
+				return
+			}
+
+			require.Equal(t, 200, response.Code, response.Body.String())
+			assert.Equal(t, test.expectedLevel, entry.atomicLevel.Level())
+			assert.Equal(t, test.expectedTrace, entry.isTraceEnabled())
+		})
+	}
+}
+
+func TestSwitcherServer_TTL(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	handler := registry.LevelSwitcherHandler()
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"inputs":"lib","level":"trace","ttl":"1h"}`)))
+	require.Equal(t, 200, response.Code, response.Body.String())
+
+	statuses := getLoggers(t, handler, "/loggers/lib")
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "debug", statuses[0].Level)
+	assert.True(t, statuses[0].Trace)
+	assert.Equal(t, originStatus{Source: "switcher_server", Key: "lib", Ordering: 1, TTL: "1h0m0s"}, statuses[0].Origin)
+	require.NotNil(t, statuses[0].PendingRevert)
+	assert.Equal(t, "error", statuses[0].PendingRevert.Level)
+	assert.False(t, statuses[0].PendingRevert.Trace)
+	assert.Equal(t, originStatus{Source: "default"}, statuses[0].PendingRevert.Origin)
+
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodDelete, "/", strings.NewReader(`{"inputs":"lib"}`)))
+	require.Equal(t, 200, response.Code, response.Body.String())
+
+	statuses = getLoggers(t, handler, "/loggers/lib")
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "error", statuses[0].Level)
+	assert.False(t, statuses[0].Trace)
+	assert.Equal(t, originStatus{Source: "default"}, statuses[0].Origin)
+	assert.Nil(t, statuses[0].PendingRevert)
+}
+
+func TestSwitcherServer_DenyElements(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	packageLogger(registry, "noisy", "com/lib/noisy")
+	handler := registry.LevelSwitcherHandler()
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"inputs":"com/lib.*,-noisy","level":"debug"}`)))
+	require.Equal(t, 200, response.Code, response.Body.String())
+
+	assert.Equal(t, "debug", getLoggers(t, handler, "/loggers/com/lib")[0].Level)
+	assert.Equal(t, "error", getLoggers(t, handler, "/loggers/com/lib/noisy")[0].Level)
+}
+
+func TestSwitcherServer_Reset(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	packageLogger(registry, "other", "com/other")
+	applicationLogger(registry, fakeEnv(map[string]string{"DLOG": "com/lib=info"}), "test", "com/test")
+	handler := registry.LevelSwitcherHandler()
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"inputs":"true","level":"debug"}`)))
+	require.Equal(t, 200, response.Code, response.Body.String())
+
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/reset", strings.NewReader(`{"inputs":"lib"}`)))
+	require.Equal(t, 200, response.Code, response.Body.String())
+
+	statuses := getLoggers(t, handler, "/loggers/com/lib")
+	assert.Equal(t, "info", statuses[0].Level)
+	assert.Equal(t, originStatus{Source: "DLOG", Key: "com/lib", Ordering: 1}, statuses[0].Origin)
+	assert.Equal(t, "debug", getLoggers(t, handler, "/loggers/com/other")[0].Level)
+}
+
+func TestSwitcherServer_MountedOnMuxActsOnOwnRegistry(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+
+	mux := http.NewServeMux()
+	mux.Handle("/admin/logging/", http.StripPrefix("/admin/logging", registry.LevelSwitcherHandler()))
+
+	response := httptest.NewRecorder()
+	mux.ServeHTTP(response, httptest.NewRequest(http.MethodPut, "/admin/logging/", strings.NewReader(`{"inputs":"lib","level":"debug"}`)))
+	require.Equal(t, 200, response.Code, response.Body.String())
+
+	statuses := getLoggers(t, mux, "/admin/logging/loggers/com/lib")
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "debug", statuses[0].Level)
+
+	_, found := globalRegistry.entryByPackageID("com/lib")
+	assert.False(t, found, "the global registry should not have been touched")
+}
+
+func TestSwitcherServer_UnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logging.sock")
+
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	applicationLogger(registry, noEnv, "test", "com/test", WithLogLevelSwitcherServerAutoStart(), WithLogLevelSwitcherServerUnixSocket(path))
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+
+	require.Eventually(t, func() bool {
+		info, err := os.Stat(path)
+		return err == nil && info.Mode().Perm() == 0600
+	}, time.Second, 5*time.Millisecond, "socket should be created and accessible only by the user")
+
+	request, err := http.NewRequest(http.MethodPut, "http://localhost/", strings.NewReader(`{"inputs":"lib","level":"debug"}`))
+	require.NoError(t, err)
+
+	response, err := client.Do(request)
+	require.NoError(t, err)
+	response.Body.Close()
+	require.Equal(t, 200, response.StatusCode)
+
+	assert.Equal(t, zapcore.DebugLevel, levelOf(registry, "com/lib"))
+}
+
+func TestListenSwitcherServer_UnixSocketInUse(t *testing.T) {
+	addr := unixSocketAddrPrefix + filepath.Join(t.TempDir(), "logging.sock")
+
+	listener, err := listenSwitcherServer(addr)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	_, err = listenSwitcherServer(addr)
+	assert.Error(t, err)
+}
+
+func TestListenSwitcherServer_StaleUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logging.sock")
+
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	// Simulates a socket file left over by a process that died
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+
+	listener, err = listenSwitcherServer(unixSocketAddrPrefix + path)
+	require.NoError(t, err)
+	listener.Close()
+}
+
+func TestSwitcherServer_Token(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	handler := registry.LevelSwitcherHandler(SwitcherServerToken("secret"))
+
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{"no token", "", 401},
+		{"invalid token", "Bearer invalid", 401},
+		{"token without bearer", "secret-suffix", 401},
+		{"raw token", "secret", 401},
+		{"other scheme", "Basic secret", 401},
+		{"bearer only", "Bearer", 401},
+		{"valid token", "Bearer secret", 200},
+		{"lower case scheme", "bearer secret", 200},
+		{"upper case scheme", "BEARER secret", 200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, request := range []*http.Request{
+				httptest.NewRequest(http.MethodGet, "/", nil),
+				httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"inputs":"lib","level":"debug"}`)),
+			} {
+				if test.authorization != "" {
+					request.Header.Set("Authorization", test.authorization)
+				}
+
+				response := httptest.NewRecorder()
+				handler.ServeHTTP(response, request)
+
+				assert.Equal(t, test.expectedStatus, response.Code, request.Method)
+			}
+		})
+	}
+}
+
+func TestSwitcherServer_Methods(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	handler := registry.LevelSwitcherHandler()
+
+	tests := []struct {
+		method         string
+		expectedStatus int
+	}{
+		{http.MethodGet, 200},
+		{http.MethodPut, 200},
+		{http.MethodPost, 200},
+		{http.MethodDelete, 200},
+		{http.MethodPatch, 405},
+		{http.MethodHead, 405},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, httptest.NewRequest(test.method, "/", strings.NewReader(`{"inputs":"lib","level":"debug"}`)))
+
+			assert.Equal(t, test.expectedStatus, response.Code)
+		})
+	}
+}
+
+func TestSwitcherServer_UnknownPaths(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	handler := registry.LevelSwitcherHandler()
+
+	for _, method := range []string{http.MethodPut, http.MethodPost, http.MethodDelete} {
+		for _, path := range []string{"/loggers/lib", "/anything", "/reset/lib"} {
+			t.Run(method+" "+path, func(t *testing.T) {
+				response := httptest.NewRecorder()
+				handler.ServeHTTP(response, httptest.NewRequest(method, path, strings.NewReader(`{"inputs":"lib","level":"debug"}`)))
+
+				assert.Equal(t, 404, response.Code)
+				assert.Equal(t, zapcore.ErrorLevel, levelOf(registry, "com/lib"))
+			})
+		}
+	}
+}
+
+func TestSwitcherServer_RequestTooLarge(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+	handler := registry.LevelSwitcherHandler()
+
+	body := `{"inputs":"lib` + strings.Repeat(",lib", maxSwitcherServerRequestSize) + `","level":"debug"}`
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body)))
+
+	assert.Equal(t, 400, response.Code)
+	assert.Equal(t, zapcore.ErrorLevel, levelOf(registry, "com/lib"))
+}
+
+func TestSwitcherServer_AuditLog(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+
+	core, logs := observer.New(zap.InfoLevel)
+	handler := registry.LevelSwitcherHandler(SwitcherServerAuditLogger(zap.New(core)))
+
+	request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"inputs":"lib","level":"debug","ttl":"1m"}`))
+	request.RemoteAddr = "10.0.0.1:4567"
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "log level changed through level switcher server", logs.All()[0].Message)
+	assert.Equal(t, map[string]interface{}{
+		"remote_addr": "10.0.0.1:4567",
+		"inputs":      "lib",
+		"level":       "debug",
+		"trace":       false,
+		"ttl":         time.Minute,
+		"loggers":     []interface{}{"com/lib"},
+	}, logs.All()[0].ContextMap())
+}
+
+func TestSwitcherServer_AuditLogIgnoresRootLevel(t *testing.T) {
+	registry := newRegistry("test", dbgZlog)
+	packageLogger(registry, "lib", "com/lib")
+
+	out := &memorySyncer{}
+	rootLogger(registry, "test", "com/test")
+	instantiateLoggers(registry, fakeEnv(map[string]string{"DLOG": "test=error"}), newInstantiateOptions(
+		WithSink("audit", SinkToWriter(out), SinkFormat(FormatLogfmt)),
+		WithProductionDetector(func() bool { return false }),
+	))
+	require.Equal(t, zapcore.ErrorLevel, levelOf(registry, "com/test"))
+
+	handler := registry.LevelSwitcherHandler()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"inputs":"lib","level":"debug"}`)))
+
+	assert.Contains(t, out.String(), `msg="log level changed through level switcher server"`)
+	assert.Equal(t, zapcore.ErrorLevel, levelOf(registry, "com/test"))
+}
+
+func getLoggers(t *testing.T, handler http.Handler, path string) []loggerStatus {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+	require.Equal(t, 200, response.Code, response.Body.String())
+
+	var out loggersResp
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &out))
+
+	return out.Loggers
+}
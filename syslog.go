@@ -0,0 +1,486 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// The syslog facilities most commonly used by applications, see `SyslogFacility`.
+const (
+	SyslogFacilityUser   = 1
+	SyslogFacilityDaemon = 3
+	SyslogFacilityLocal0 = 16
+	SyslogFacilityLocal1 = 17
+	SyslogFacilityLocal2 = 18
+	SyslogFacilityLocal3 = 19
+	SyslogFacilityLocal4 = 20
+	SyslogFacilityLocal5 = 21
+	SyslogFacilityLocal6 = 22
+	SyslogFacilityLocal7 = 23
+)
+
+// syslogSeverities maps the levels to the syslog severities, Fatal entries are reported as
+// `alert` rather than `emerg` which is usually broadcast to all the terminals of the host.
+var syslogSeverities = map[zapcore.Level]int{
+	zapcore.DebugLevel:  7,
+	zapcore.InfoLevel:   6,
+	zapcore.WarnLevel:   4,
+	zapcore.ErrorLevel:  3,
+	zapcore.DPanicLevel: 2,
+	zapcore.PanicLevel:  2,
+	zapcore.FatalLevel:  1,
+}
+
+// syslogLocalAddresses are the paths of the local syslog daemon socket tried in order when no
+// address is given.
+var syslogLocalAddresses = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+const (
+	syslogDialTimeout  = 5 * time.Second
+	syslogWriteTimeout = 5 * time.Second
+
+	// syslogRetryDelay is the time during which writes fail right away after a failed connection,
+	// so that an unreachable syslog daemon doesn't slow down every logging call.
+	syslogRetryDelay = time.Second
+)
+
+type syslogOptions struct {
+	facility         int
+	appName          string
+	rfc3164          bool
+	structuredDataID string
+}
+
+// SyslogOption configures the syslog output of a sink, see `SinkToSyslog`.
+type SyslogOption interface {
+	apply(o *syslogOptions)
+}
+
+type syslogOptionFunc func(o *syslogOptions)
+
+func (f syslogOptionFunc) apply(o *syslogOptions) {
+	f(o)
+}
+
+// SyslogFacility is the facility of the messages, between 0 and 23, `SyslogFacilityUser` by
+// default. The function panics if the facility is out of range.
+func SyslogFacility(facility int) SyslogOption {
+	if facility < 0 || facility > 23 {
+		panic(fmt.Errorf("invalid syslog facility %d, it must be between 0 and 23", facility))
+	}
+
+	return syslogOptionFunc(func(o *syslogOptions) {
+		o.facility = facility
+	})
+}
+
+// SyslogAppName is the app name of the messages (the tag in RFC 3164), the short name of the root
+// logger by default, or the process name if there is no root logger.
+func SyslogAppName(name string) SyslogOption {
+	return syslogOptionFunc(func(o *syslogOptions) {
+		o.appName = name
+	})
+}
+
+// SyslogRFC3164 writes the messages in the legacy BSD format, the fields being appended to the
+// message in logfmt instead of being written as structured data.
+func SyslogRFC3164() SyslogOption {
+	return syslogOptionFunc(func(o *syslogOptions) {
+		o.rfc3164 = true
+	})
+}
+
+// SyslogStructuredDataID is the ID of the RFC 5424 structured data element holding the fields,
+// `fields@32473` by default, 32473 being the private enterprise number reserved for examples.
+func SyslogStructuredDataID(id string) SyslogOption {
+	return syslogOptionFunc(func(o *syslogOptions) {
+		o.structuredDataID = id
+	})
+}
+
+// syslogOutput is the syslog output of a sink, its writer is shared by all the loggers.
+type syslogOutput struct {
+	options syslogOptions
+	writer  *syslogWriter
+}
+
+// SinkToSyslog writes the sink's entries to a syslog daemon in RFC 5424 (or RFC 3164 with
+// `SyslogRFC3164`), the fields becoming structured data. The `network` is one of `unixgram`,
+// `unix`, `udp` or `tcp` (using octet counting framing), an empty network and address connect to
+// the local daemon socket (`/dev/log`). The connection is established on the first entry and
+// re-established when writing fails.
+//
+// The sink writes its own format, `SinkFormat` is ignored.
+func SinkToSyslog(network string, address string, options ...SyslogOption) SinkOption {
+	output := &syslogOutput{
+		options: syslogOptions{facility: SyslogFacilityUser, structuredDataID: "fields@32473"},
+		writer:  newSyslogWriter(network, address),
+	}
+
+	for _, opt := range options {
+		opt.apply(&output.options)
+	}
+
+	return sinkOptionFunc(func(s *sink) {
+		s.output = sinkOutput{syslog: output}
+	})
+}
+
+// syslogCore is a `zapcore.Core` writing each entry as a syslog message, the `writer` receiving
+// one message per write.
+type syslogCore struct {
+	zapcore.LevelEnabler
+
+	writer   zapcore.WriteSyncer
+	options  syslogOptions
+	hostname string
+	pid      int
+	fields   []zapcore.Field
+}
+
+func newSyslogCore(writer zapcore.WriteSyncer, level zapcore.LevelEnabler, options syslogOptions) *syslogCore {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = ""
+	}
+
+	if options.appName == "" {
+		options.appName = filepath.Base(os.Args[0])
+	}
+
+	return &syslogCore{LevelEnabler: level, writer: writer, options: options, hostname: hostname, pid: os.Getpid()}
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append([]zapcore.Field(nil), c.fields...), fields...)
+
+	return &clone
+}
+
+func (c *syslogCore) Check(ent zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return checked.AddCore(ent, c)
+	}
+
+	return checked
+}
+
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	allFields := append(append([]zapcore.Field(nil), c.fields...), fields...)
+
+	var message []byte
+	if c.options.rfc3164 {
+		message = c.encodeRFC3164(ent, allFields)
+	} else {
+		message = c.encodeRFC5424(ent, allFields)
+	}
+
+	if _, err := c.writer.Write(message); err != nil {
+		return err
+	}
+
+	// Like zap's own cores, the entries that may end the process are synced right away
+	if ent.Level > zapcore.ErrorLevel {
+		c.Sync()
+	}
+
+	return nil
+}
+
+func (c *syslogCore) Sync() error {
+	return c.writer.Sync()
+}
+
+func (c *syslogCore) priority(level zapcore.Level) int {
+	severity, found := syslogSeverities[level]
+	if !found {
+		severity = syslogSeverities[zapcore.DebugLevel]
+	}
+
+	return c.options.facility*8 + severity
+}
+
+// encodeRFC5424 writes `<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG`, the logger name
+// being the message ID.
+func (c *syslogCore) encodeRFC5424(ent zapcore.Entry, fields []zapcore.Field) []byte {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "<%d>1 %s %s %s %d %s ",
+		c.priority(ent.Level),
+		ent.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(c.hostname, 255),
+		syslogHeaderField(c.options.appName, 48),
+		c.pid,
+		syslogHeaderField(ent.LoggerName, 32),
+	)
+
+	params := syslogParams(ent, fields)
+	if len(params) == 0 {
+		buf.WriteString("-")
+	} else {
+		buf.WriteString("[")
+		buf.WriteString(syslogHeaderField(c.options.structuredDataID, 32))
+
+		for _, param := range params {
+			buf.WriteString(" ")
+			buf.WriteString(syslogParamName(param.name))
+			buf.WriteString(`="`)
+			syslogParamValueEscaper.WriteString(buf, param.value)
+			buf.WriteString(`"`)
+		}
+
+		buf.WriteString("]")
+	}
+
+	buf.WriteString(" ")
+	buf.WriteString(ent.Message)
+	if ent.Stack != "" {
+		buf.WriteString("\n")
+		buf.WriteString(ent.Stack)
+	}
+
+	return buf.Bytes()
+}
+
+// encodeRFC3164 writes `<PRI>TIMESTAMP HOSTNAME TAG[PID]: MSG key=value...`.
+func (c *syslogCore) encodeRFC3164(ent zapcore.Entry, fields []zapcore.Field) []byte {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "<%d>%s %s %s[%d]: %s",
+		c.priority(ent.Level),
+		ent.Time.Format(time.Stamp),
+		syslogHeaderField(c.hostname, 255),
+		syslogHeaderField(c.options.appName, 32),
+		c.pid,
+		ent.Message,
+	)
+
+	if ent.Caller.Defined {
+		fields = append([]zapcore.Field{{Key: "caller", Type: zapcore.StringType, String: ent.Caller.TrimmedPath()}}, fields...)
+	}
+
+	if len(fields) > 0 {
+		// All the keys of the encoder are empty, it renders only the fields
+		encoded, err := NewLogfmtEncoder(zapcore.EncoderConfig{EncodeDuration: zapcore.StringDurationEncoder}).EncodeEntry(zapcore.Entry{}, fields)
+		if err == nil {
+			buf.WriteString(" ")
+			buf.Write(bytes.TrimSpace(encoded.Bytes()))
+			encoded.Free()
+		}
+	}
+
+	if ent.Stack != "" {
+		buf.WriteString("\n")
+		buf.WriteString(ent.Stack)
+	}
+
+	return buf.Bytes()
+}
+
+type stringField struct {
+	name  string
+	value string
+}
+
+// syslogParams returns the caller and the fields as structured data parameters, in the order
+// of the fields.
+func syslogParams(ent zapcore.Entry, fields []zapcore.Field) (out []stringField) {
+	if ent.Caller.Defined {
+		out = append(out, stringField{"caller", ent.Caller.TrimmedPath()})
+	}
+
+	return append(out, stringFields(fields)...)
+}
+
+// stringFields flattens the fields to strings, in the order of the fields, the keys added by a
+// single field being sorted. Values other than strings, durations and times are written in JSON.
+func stringFields(fields []zapcore.Field) (out []stringField) {
+	for _, field := range fields {
+		encoder := zapcore.NewMapObjectEncoder()
+		field.AddTo(encoder)
+
+		names := make([]string, 0, len(encoder.Fields))
+		for name := range encoder.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			out = append(out, stringField{name, stringFieldValue(encoder.Fields[name])})
+		}
+	}
+
+	return
+}
+
+func stringFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Duration:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+
+	if encoded, err := json.Marshal(value); err == nil {
+		return string(encoded)
+	}
+
+	return fmt.Sprint(value)
+}
+
+var syslogParamValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogHeaderField returns `value` limited to `maxLength` printable ASCII characters, the other
+// characters being replaced by `_`, or the nil value `-` if empty.
+func syslogHeaderField(value string, maxLength int) string {
+	if value == "" {
+		return "-"
+	}
+
+	out := []byte(value)
+	for i, c := range out {
+		if c < 33 || c > 126 {
+			out[i] = '_'
+		}
+	}
+
+	if len(out) > maxLength {
+		out = out[:maxLength]
+	}
+
+	return string(out)
+}
+
+// syslogParamName returns `name` as a valid structured data parameter name, which is a header
+// field that cannot contain `=`, `]` and `"`.
+func syslogParamName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+
+		return r
+	}, syslogHeaderField(name, 32))
+}
+
+// syslogWriter sends each write as a syslog message, framed according to the network, connecting
+// on the first write and reconnecting when writing fails.
+type syslogWriter struct {
+	network string
+	address string
+
+	lock    sync.Mutex
+	conn    net.Conn
+	framing func(message []byte) []byte
+	retryAt time.Time
+	dialErr error
+}
+
+func newSyslogWriter(network string, address string) *syslogWriter {
+	return &syslogWriter{network: network, address: address}
+}
+
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if err := w.connect(); err != nil {
+				return 0, err
+			}
+		}
+
+		w.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+		if _, err = w.conn.Write(w.framing(p)); err == nil {
+			return len(p), nil
+		}
+
+		// The connection is broken, the message is sent again on a new one
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	return 0, fmt.Errorf("write to syslog: %w", err)
+}
+
+// Sync does nothing, messages are sent as they are written.
+func (w *syslogWriter) Sync() error {
+	return nil
+}
+
+// writesMessages marks the writer as expecting a single message per write, see `messageWriter`.
+func (w *syslogWriter) writesMessages() {}
+
+func (w *syslogWriter) connect() error {
+	if time.Now().Before(w.retryAt) {
+		return w.dialErr
+	}
+
+	conn, network, err := w.dial()
+	if err != nil {
+		w.retryAt = time.Now().Add(syslogRetryDelay)
+		w.dialErr = err
+		return err
+	}
+
+	w.conn = conn
+	w.framing = syslogFraming(network)
+
+	return nil
+}
+
+func (w *syslogWriter) dial() (net.Conn, string, error) {
+	if w.network != "" || w.address != "" {
+		conn, err := net.DialTimeout(w.network, w.address, syslogDialTimeout)
+		if err != nil {
+			return nil, "", fmt.Errorf("connect to syslog: %w", err)
+		}
+
+		return conn, w.network, nil
+	}
+
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, address := range syslogLocalAddresses {
+			if conn, err := net.DialTimeout(network, address, syslogDialTimeout); err == nil {
+				return conn, network, nil
+			}
+		}
+	}
+
+	return nil, "", fmt.Errorf("connect to syslog: no local syslog socket found among %s", strings.Join(syslogLocalAddresses, ", "))
+}
+
+// syslogFraming returns how the messages are delimited on the network, stream connections need
+// a delimiter, TCP ones using the octet counting of RFC 6587.
+func syslogFraming(network string) func(message []byte) []byte {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return func(message []byte) []byte {
+			return append([]byte(strconv.Itoa(len(message))+" "), message...)
+		}
+	case "unix":
+		return func(message []byte) []byte {
+			return append(append([]byte(nil), message...), '\n')
+		}
+	}
+
+	return func(message []byte) []byte {
+		return message
+	}
+}
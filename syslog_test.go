@@ -0,0 +1,241 @@
+package logging
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var syslogTestEntry = zapcore.Entry{
+	Level:      zapcore.WarnLevel,
+	Time:       time.Date(2021, 3, 4, 5, 6, 7, 8000, time.UTC),
+	LoggerName: "p2p",
+	Caller:     zapcore.EntryCaller{Defined: true, File: "/src/github.com/acme/app/main.go", Line: 12},
+	Message:    "peer disconnected",
+}
+
+func TestSyslogCore_RFC5424(t *testing.T) {
+	out := &memorySyncer{}
+	core := newTestSyslogCore(out, syslogOptions{facility: SyslogFacilityLocal0, appName: "my app", structuredDataID: "fields@32473"})
+
+	err := core.With([]zapcore.Field{zap.String("peer", `10.0.0.1 "eu]"`)}).Write(syslogTestEntry, []zapcore.Field{zap.Int("attempt", 2), zap.Duration("elapsed", time.Second), zap.Error(errors.New("timeout")), zap.Strings("tags", []string{"a", "b"})})
+	require.NoError(t, err)
+
+	assert.Equal(t, `<132>1 2021-03-04T05:06:07.000008Z host my_app 42 p2p [fields@32473 caller="app/main.go:12" peer="10.0.0.1 \"eu\]\"" attempt="2" elapsed="1s" error="timeout" tags="[\"a\",\"b\"\]"] peer disconnected`, out.String())
+}
+
+func TestSyslogCore_RFC5424_NoStructuredData(t *testing.T) {
+	out := &memorySyncer{}
+	core := newTestSyslogCore(out, syslogOptions{facility: SyslogFacilityUser, appName: "app", structuredDataID: "fields@32473"})
+
+	require.NoError(t, core.Write(zapcore.Entry{Level: zapcore.FatalLevel, Time: syslogTestEntry.Time, Message: "exiting", Stack: "main.main"}, nil))
+
+	assert.Equal(t, "<9>1 2021-03-04T05:06:07.000008Z host app 42 - - exiting\nmain.main", out.String())
+	assert.Equal(t, 1, out.syncs)
+}
+
+func TestSyslogCore_RFC3164(t *testing.T) {
+	out := &memorySyncer{}
+	core := newTestSyslogCore(out, syslogOptions{facility: SyslogFacilityDaemon, appName: "app", rfc3164: true})
+
+	require.NoError(t, core.Write(syslogTestEntry, []zapcore.Field{zap.String("peer", "10.0.0.1"), zap.Int("attempt", 2)}))
+
+	assert.Equal(t, `<28>Mar  4 05:06:07 host app[42]: peer disconnected caller=app/main.go:12 peer=10.0.0.1 attempt=2`, out.String())
+}
+
+func TestSinkToSyslog(t *testing.T) {
+	unixgramAddress := filepath.Join(t.TempDir(), "log.sock")
+
+	tests := []struct {
+		network string
+		listen  func(t *testing.T) (address string, messages <-chan string)
+	}{
+		{"udp", func(t *testing.T) (string, <-chan string) { return listenSyslogPacket(t, "udp", "127.0.0.1:0") }},
+		{"unixgram", func(t *testing.T) (string, <-chan string) { return listenSyslogPacket(t, "unixgram", unixgramAddress) }},
+		{"tcp", func(t *testing.T) (string, <-chan string) {
+			server := listenSyslogTCP(t)
+			return server.address, server.messages
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.network, func(t *testing.T) {
+			address, messages := test.listen(t)
+
+			registry := newRegistry("test", dbgZlog)
+			logger, _ := applicationLogger(registry, noEnv, "app", "github.com/acme/app",
+				WithSink("syslog", SinkToSyslog(test.network, address)),
+				WithProductionDetector(func() bool { return false }),
+			)
+
+			logger.Info("first", zap.String("peer", "10.0.0.1"))
+			logger.Warn("second")
+
+			assert.Regexp(t, `^<14>1 \S+ \S+ app \d+ app \[fields@32473 peer="10.0.0.1"\] first$`, receive(t, messages))
+			assert.Regexp(t, `^<12>1 \S+ \S+ app \d+ app - second$`, receive(t, messages))
+		})
+	}
+}
+
+func TestSyslogWriter_Reconnect(t *testing.T) {
+	server := listenSyslogTCP(t)
+	writer := newSyslogWriter("tcp", server.address)
+
+	_, err := writer.Write([]byte("before"))
+	require.NoError(t, err)
+	assert.Equal(t, "before", receive(t, server.messages))
+
+	server.dropConnections()
+
+	// Writing to the dropped connection may succeed until the peer reset is noticed
+	require.Eventually(t, func() bool {
+		writer.Write([]byte("after"))
+
+		select {
+		case message := <-server.messages:
+			return message == "after"
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestSyslogWriter_RetryDelay(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	writer := newSyslogWriter("tcp", address)
+
+	_, err = writer.Write([]byte("message"))
+	require.Error(t, err)
+
+	// The daemon is not dialed again before the retry delay
+	_, retryErr := writer.Write([]byte("message"))
+	assert.Equal(t, err, retryErr)
+}
+
+func newTestSyslogCore(out zapcore.WriteSyncer, options syslogOptions) *syslogCore {
+	core := newSyslogCore(out, zapcore.DebugLevel, options)
+	core.hostname = "host"
+	core.pid = 42
+
+	return core
+}
+
+func listenSyslogPacket(t *testing.T, network string, address string) (string, <-chan string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket(network, address)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	messages := make(chan string, 100)
+	go func() {
+		buffer := make([]byte, 65536)
+		for {
+			n, _, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+
+			messages <- string(buffer[:n])
+		}
+	}()
+
+	return conn.LocalAddr().String(), messages
+}
+
+// syslogTCPServer receives octet counted messages on all the accepted connections.
+type syslogTCPServer struct {
+	address  string
+	messages chan string
+
+	lock  sync.Mutex
+	conns []net.Conn
+}
+
+func listenSyslogTCP(t *testing.T) *syslogTCPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	server := &syslogTCPServer{address: listener.Addr().String(), messages: make(chan string, 100)}
+	t.Cleanup(server.dropConnections)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			server.lock.Lock()
+			server.conns = append(server.conns, conn)
+			server.lock.Unlock()
+
+			go server.read(conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *syslogTCPServer) read(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			return
+		}
+
+		size, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		if err != nil {
+			return
+		}
+
+		message := make([]byte, size)
+		if _, err := io.ReadFull(reader, message); err != nil {
+			return
+		}
+
+		s.messages <- string(message)
+	}
+}
+
+func (s *syslogTCPServer) dropConnections() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func receive(t *testing.T, messages <-chan string) string {
+	t.Helper()
+
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no message received")
+		return ""
+	}
+}